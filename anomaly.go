@@ -0,0 +1,84 @@
+package main
+
+import (
+  "fmt"
+  "math"
+  "strings"
+)
+
+// Fewest datapoints in the rolling window before a point is scored at all, since a standard deviation over a handful of points flags nearly everything
+const minZScoreSamples = 5
+
+// Most anomalies named in the footer, so a noisy metric doesn't push the graph off the screen
+const maxListedAnomalies = 10
+
+// Overlay the points more than k standard deviations from the mean of the window datapoints before them in red, and list them in the footer.
+// Gap-filled buckets aren't datapoints, so they're neither scored nor counted towards the window.
+func highlightAnomalies(panel Panel, k float64, window int) Panel {
+  highlighted := make(Series, len(panel.Series))
+  for i, point := range panel.Series {
+    highlighted[i] = Point{ Timestamp: point.Timestamp, Value: math.NaN(), Filled: true }
+  }
+  listed := []string{}
+  anomalies, scored := 0, 0
+  previous := []float64{}
+  for i, point := range panel.Series {
+    if point.Filled || math.IsNaN(point.Value) {
+      continue
+    }
+
+    if len(previous) >= minZScoreSamples {
+      mean, stddev := meanAndStddev(previous)
+      // A window that never varied gives no scale to measure a change against
+      if stddev > 0 {
+        scored++
+        if z := (point.Value - mean) / stddev; math.Abs(z) > k {
+          // With the segments to its neighbours, since asciigraph stretches the series to the width and a lone point between breaks would be lost
+          for j := i - 1; j <= i + 1; j++ {
+            if j >= 0 && j < len(panel.Series) && !math.IsNaN(panel.Series[j].Value) {
+              highlighted[j] = panel.Series[j]
+            }
+          }
+          anomalies++
+          if len(listed) < maxListedAnomalies {
+            listed = append(listed, fmt.Sprintf("%s (%+.1fσ)", formatTime(point.Timestamp), z))
+          }
+        }
+      }
+    }
+
+    previous = append(previous, point.Value)
+    if len(previous) > window {
+      previous = previous[1:]
+    }
+  }
+
+  description := fmt.Sprintf("beyond %gσ of the rolling %d-point mean", k, window)
+  switch {
+  case scored == 0:
+    panel.Footer = append(panel.Footer, fmt.Sprintf("Too few datapoints to find points %s, which needs more than %d that aren't all the same", description, minZScoreSamples))
+    return panel
+  case anomalies == 0:
+    panel.Footer = append(panel.Footer, fmt.Sprintf("No points %s", description))
+    return panel
+  }
+
+  if anomalies > len(listed) {
+    listed = append(listed, fmt.Sprintf("and %d more", anomalies - len(listed)))
+  }
+  panel.Footer = append(panel.Footer, fmt.Sprintf("%d point(s) %s: %s", anomalies, description, strings.Join(listed, ", ")))
+  panel.Overlays = append(append([]Panel{}, panel.Overlays...), Panel{ Label: "anomalies", Series: highlighted, Color: "red" })
+
+  return panel
+}
+
+// Population standard deviation, since the window is all there is to go on
+func meanAndStddev(values []float64) (float64, float64) {
+  mean := sum(values) / float64(len(values))
+  variance := 0.0
+  for _, value := range values {
+    variance += (value - mean) * (value - mean)
+  }
+
+  return mean, math.Sqrt(variance / float64(len(values)))
+}
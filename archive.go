@@ -0,0 +1,239 @@
+package main
+
+import (
+  "compress/gzip"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io"
+  "math"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+  "time"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/cloudwatch"
+  "github.com/aws/aws-sdk-go/service/s3"
+)
+
+// One record of a CloudWatch metric stream in its JSON output format: a minute of one metric, summarized by a few statistics
+type metricStreamRecord struct {
+  Namespace string `json:"namespace"`
+  MetricName string `json:"metric_name"`
+  Dimensions map[string]string `json:"dimensions"`
+  // Unix milliseconds at the start of the minute
+  Timestamp int64 `json:"timestamp"`
+  // max, min, sum, and count, plus any percentiles the stream was configured with, e.g. p99
+  Value map[string]float64 `json:"value"`
+}
+
+// The statistics of one -period bucket, combined from the records falling in it
+type archivedBucket struct {
+  min, max, sum, count float64
+  // Percentiles can't be combined across records, so they're only kept when a bucket holds exactly one
+  percentiles map[string]float64
+  records int
+}
+
+// Draw the -metric queries from metric stream records archived under -archive, a file, a directory of them, or an s3://bucket/prefix.
+// The window ends at the newest matching record rather than now, since the point of an archive is history CloudWatch no longer keeps.
+func (client Client) renderArchive(options Options) error {
+  if strings.HasPrefix(options.stat, "p") && options.period != 60 {
+    return fmt.Errorf("-archive can only show %s at -period 60, since metric streams publish percentiles per minute and they can't be re-aggregated", options.stat)
+  }
+
+  buckets := make([]map[int64]*archivedBucket, len(options.queries))
+  for i := range buckets {
+    buckets[i] = map[int64]*archivedBucket{}
+  }
+  period := time.Duration(options.period) * time.Second
+  err := client.readArchive(options.archive, func (record metricStreamRecord) {
+    for i, query := range options.queries {
+      if !record.matches(query) {
+        continue
+      }
+      timestamp := time.UnixMilli(record.Timestamp).Truncate(period).Unix()
+      bucket, ok := buckets[i][timestamp]
+      if !ok {
+        bucket = &archivedBucket{ min: math.Inf(1), max: math.Inf(-1) }
+        buckets[i][timestamp] = bucket
+      }
+      bucket.add(record)
+    }
+  })
+  if err != nil {
+    return fmt.Errorf("cannot read -archive: %w", err)
+  }
+
+  var end time.Time
+  for i, query := range options.queries {
+    if len(buckets[i]) == 0 {
+      return fmt.Errorf("%w for %s/%s in %s", ErrNoData, query.Namespace, query.Label(), options.archive)
+    }
+    for timestamp := range buckets[i] {
+      if bucketEnd := time.Unix(timestamp, 0).Add(period); bucketEnd.After(end) {
+        end = bucketEnd
+      }
+    }
+  }
+  start := end.Add(options.lookback)
+
+  panels := []Panel{}
+  for i, query := range options.queries {
+    points := []Point{}
+    for timestamp, bucket := range buckets[i] {
+      at := time.Unix(timestamp, 0)
+      if at.Before(start) {
+        continue
+      }
+      value, ok := bucket.statistic(query.Stat)
+      if !ok {
+        return fmt.Errorf("%s in %s has no %s; metric streams only carry Minimum, Maximum, Sum, SampleCount, and Average, plus the percentiles they were configured with", query.Label(), options.archive, query.Stat)
+      }
+      points = append(points, Point{ Timestamp: at, Value: value })
+    }
+    sort.Slice(points, func (a, b int) bool {
+      return points[a].Timestamp.Before(points[b].Timestamp)
+    })
+    series := fillGaps(points, start, period, options.fillPolicy())
+    if options.trimZeros {
+      series = series.TrimFilled()
+    }
+    query := query
+    panels = append(panels, Panel{ Label: query.Label(), Series: series, Query: &query })
+  }
+
+  return render(transformPanels(options, panels, end), options.namespace, options.lookback, end)
+}
+
+// Whether the record is of the queried metric, with exactly its dimensions as CloudWatch would match them
+func (record metricStreamRecord) matches(query MetricQuery) bool {
+  if record.Namespace != query.Namespace || record.MetricName != query.Metric || len(record.Dimensions) != len(query.Dimensions) {
+    return false
+  }
+  for _, dimension := range query.Dimensions {
+    if value, ok := record.Dimensions[aws.StringValue(dimension.Name)]; !ok || value != aws.StringValue(dimension.Value) {
+      return false
+    }
+  }
+
+  return true
+}
+
+func (bucket *archivedBucket) add(record metricStreamRecord) {
+  bucket.min = math.Min(bucket.min, record.Value["min"])
+  bucket.max = math.Max(bucket.max, record.Value["max"])
+  bucket.sum += record.Value["sum"]
+  bucket.count += record.Value["count"]
+  bucket.records++
+  if bucket.records > 1 {
+    bucket.percentiles = nil
+    return
+  }
+  bucket.percentiles = map[string]float64{}
+  for name, value := range record.Value {
+    if strings.HasPrefix(name, "p") {
+      bucket.percentiles[name] = value
+    }
+  }
+}
+
+func (bucket *archivedBucket) statistic(stat string) (float64, bool) {
+  switch stat {
+  case cloudwatch.StatisticMinimum:
+    return bucket.min, true
+  case cloudwatch.StatisticMaximum:
+    return bucket.max, true
+  case cloudwatch.StatisticSum:
+    return bucket.sum, true
+  case cloudwatch.StatisticSampleCount:
+    return bucket.count, true
+  case cloudwatch.StatisticAverage:
+    if bucket.count == 0 {
+      return math.NaN(), true
+    }
+    return bucket.sum / bucket.count, true
+  }
+
+  // Streams name percentiles in lowercase, e.g. p99 or p99.9
+  value, ok := bucket.percentiles[strings.ToLower(stat)]
+  return value, ok
+}
+
+// Pass every record under path to each. Files ending in .gz, as Firehose writes them when compression is on, are decompressed.
+func (client Client) readArchive(path string, each func (record metricStreamRecord)) error {
+  if strings.HasPrefix(path, "s3://") {
+    return client.readS3Archive(strings.TrimPrefix(path, "s3://"), each)
+  }
+
+  return filepath.Walk(path, func (file string, info os.FileInfo, err error) error {
+    if err != nil || info.IsDir() {
+      return err
+    }
+    opened, err := os.Open(file)
+    if err != nil {
+      return err
+    }
+    defer opened.Close()
+    return readArchiveObject(file, opened, each)
+  })
+}
+
+// Read every object under the bucket/prefix location
+func (client Client) readS3Archive(location string, each func (record metricStreamRecord)) error {
+  parts := strings.SplitN(location, "/", 2)
+  input := &s3.ListObjectsV2Input{ Bucket: aws.String(parts[0]) }
+  if len(parts) == 2 && parts[1] != "" {
+    input.Prefix = aws.String(parts[1])
+  }
+
+  connection := s3.New(client.session)
+  var readErr error
+  err := connection.ListObjectsV2Pages(input, func (page *s3.ListObjectsV2Output, lastPage bool) bool {
+    for _, object := range page.Contents {
+      output, err := connection.GetObject(&s3.GetObjectInput{ Bucket: input.Bucket, Key: object.Key })
+      if err != nil {
+        readErr = err
+        return false
+      }
+      readErr = readArchiveObject("s3://" + parts[0] + "/" + aws.StringValue(object.Key), output.Body, each)
+      output.Body.Close()
+      if readErr != nil {
+        return false
+      }
+    }
+    return true
+  })
+  if err != nil {
+    return err
+  }
+
+  return readErr
+}
+
+// Decode the concatenated JSON records Firehose delivers, with or without newlines between them
+func readArchiveObject(name string, reader io.Reader, each func (record metricStreamRecord)) error {
+  if strings.HasSuffix(name, ".gz") {
+    decompressed, err := gzip.NewReader(reader)
+    if err != nil {
+      return fmt.Errorf("%s: %w", name, err)
+    }
+    defer decompressed.Close()
+    reader = decompressed
+  }
+
+  decoder := json.NewDecoder(reader)
+  for {
+    record := metricStreamRecord{}
+    err := decoder.Decode(&record)
+    if errors.Is(err, io.EOF) {
+      return nil
+    }
+    if err != nil {
+      return fmt.Errorf("%s: %w (only the metric stream JSON output format is supported, not OpenTelemetry)", name, err)
+    }
+    each(record)
+  }
+}
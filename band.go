@@ -0,0 +1,28 @@
+package main
+
+import (
+  "time"
+)
+
+// Values accepted by -band, and the percentiles bounding each. CloudWatch has no standard deviation statistic, so stddev uses p16 and p84, which bound ±1σ for normally distributed values.
+var bands = map[string][2]string{
+  "stddev": { "p16", "p84" },
+  "iqr": { "p25", "p75" },
+}
+
+// Fetch the lower and upper bounds of the -band around the query's series, drawn faintly either side of it
+func (client Client) fetchBand(options Options, query MetricQuery, start time.Time, end time.Time) ([]Panel, error) {
+  bounds := []Panel{}
+  for _, percentile := range bands[options.band] {
+    bound := query
+    bound.Stat = percentile
+    request := newMetricStatisticsRequest(bound, start, end)
+    series, err := client.getMetricSampleCounts(&request, options.fillPolicy())
+    if err != nil {
+      return bounds, err
+    }
+    bounds = append(bounds, Panel{ Label: percentile, Series: series, Query: &bound, Color: "darkgray" })
+  }
+
+  return bounds, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+  "bufio"
+  "encoding/json"
+  "fmt"
+  "math"
+  "os"
+)
+
+// Write each panel's series to path for a later -baseline, one JSON datapoint per line. Breaks can't be represented in JSON and are left out, and gap-filled buckets are marked so they aren't read back as real datapoints.
+func saveBaseline(path string, panels []Panel) error {
+  file, err := os.Create(path)
+  if err != nil {
+    return fmt.Errorf("cannot write -save-baseline file: %w", err)
+  }
+  defer file.Close()
+
+  writer := bufio.NewWriter(file)
+  encoder := json.NewEncoder(writer)
+  for _, panel := range panels {
+    for _, point := range panel.Series {
+      if math.IsNaN(point.Value) {
+        continue
+      }
+      if err := encoder.Encode(jsonDatapoint{ Metric: panel.Label, Timestamp: point.Timestamp, Value: point.Value, Filled: point.Filled }); err != nil {
+        return err
+      }
+    }
+  }
+
+  return writer.Flush()
+}
+
+// Read a file written by -save-baseline into a series per metric label
+func loadBaseline(path string) (map[string]Series, error) {
+  _, baseline, err := readDatapoints(path)
+  if err != nil {
+    return nil, fmt.Errorf("cannot read -baseline file: %w", err)
+  }
+
+  return baseline, nil
+}
+
+// Read JSON datapoints, as written by -save-baseline and -output jsonl, into a series per metric label. Labels are in the order they first appear.
+func readDatapoints(path string) ([]string, map[string]Series, error) {
+  file, err := os.Open(path)
+  if err != nil {
+    return nil, nil, err
+  }
+  defer file.Close()
+
+  labels := []string{}
+  series := map[string]Series{}
+  scanner := bufio.NewScanner(file)
+  for lineNumber := 1; scanner.Scan(); lineNumber++ {
+    datapoint := jsonDatapoint{}
+    if err := json.Unmarshal(scanner.Bytes(), &datapoint); err != nil {
+      return nil, nil, fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+    }
+    if _, ok := series[datapoint.Metric]; !ok {
+      labels = append(labels, datapoint.Metric)
+    }
+    series[datapoint.Metric] = append(series[datapoint.Metric], Point{ Timestamp: datapoint.Timestamp, Value: datapoint.Value, Filled: datapoint.Filled })
+  }
+  if err := scanner.Err(); err != nil {
+    return nil, nil, err
+  }
+  if len(series) == 0 {
+    return nil, nil, fmt.Errorf("%s: no datapoints", path)
+  }
+
+  return labels, series, nil
+}
+
+// The baseline lined up bucket for bucket with the panel, matching each by its offset from the start of its own window. Buckets the baseline doesn't cover are breaks.
+func baselineOverlay(panel Panel, baseline Series) Panel {
+  overlay := Panel{ Label: "baseline" }
+  if len(panel.Series) == 0 || len(baseline) == 0 {
+    return overlay
+  }
+
+  shifted := map[int64]Point{}
+  for _, point := range baseline.Shift(panel.Series[0].Timestamp.Sub(baseline[0].Timestamp)) {
+    shifted[point.Timestamp.Unix()] = point
+  }
+  for _, point := range panel.Series {
+    aligned, ok := shifted[point.Timestamp.Unix()]
+    if !ok {
+      aligned = Point{ Timestamp: point.Timestamp, Value: math.NaN(), Filled: true }
+    }
+    overlay.Series = append(overlay.Series, aligned)
+  }
+
+  return overlay
+}
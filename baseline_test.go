@@ -0,0 +1,35 @@
+package main
+
+import (
+  "math"
+  "path/filepath"
+  "testing"
+  "time"
+)
+
+func TestSaveBaselineKeepsFilledBuckets(t *testing.T) {
+  start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+  series := Series{
+    { Timestamp: start, Value: 0, Filled: true },
+    { Timestamp: start.Add(time.Minute), Value: 4 },
+    { Timestamp: start.Add(2 * time.Minute), Value: math.NaN(), Filled: true },
+    { Timestamp: start.Add(3 * time.Minute), Value: 6 },
+  }
+  path := filepath.Join(t.TempDir(), "baseline.jsonl")
+  if err := saveBaseline(path, []Panel{{ Label: "metric", Series: series }}); err != nil {
+    t.Fatal(err)
+  }
+
+  baseline, err := loadBaseline(path)
+  if err != nil {
+    t.Fatal(err)
+  }
+  loaded := baseline["metric"]
+  // The break is dropped, but the zero fill stays so the baseline still lines up from its first bucket
+  if len(loaded) != 3 || !loaded[0].Timestamp.Equal(start) || !loaded[0].Filled {
+    t.Fatalf("loaded %v, want the filled first bucket and both real ones", loaded)
+  }
+  if values := sortedValues(loaded); len(values) != 2 || values[0] != 4 || values[1] != 6 {
+    t.Errorf("-regression-threshold would compare against %v, want only the real 4 and 6", values)
+  }
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+  "errors"
+  "fmt"
+  "math"
+  "sort"
+  "time"
+)
+
+// Time the fetch path -benchmark times and print a latency report instead of rendering
+func (client Client) benchmark(options Options) error {
+  latencies := []time.Duration{}
+  for i := 0; i < options.benchmark; i++ {
+    end := time.Now()
+    start := end.Add(options.lookback)
+
+    began := time.Now()
+    _, err := client.fetchPanels(options, start, end)
+    elapsed := time.Now().Sub(began)
+    if err != nil && !errors.Is(err, ErrNoData) {
+      return err
+    }
+    latencies = append(latencies, elapsed)
+  }
+
+  sort.Slice(latencies, func (i, j int) bool {
+    return latencies[i] < latencies[j]
+  })
+
+  fmt.Printf("Fetched lookback=%s %d time(s) with parallelism=%d\n", options.lookback, len(latencies), options.parallelism)
+  fmt.Printf("  min    %s\n", latencies[0])
+  fmt.Printf("  median %s\n", percentile(latencies, 50))
+  fmt.Printf("  p95    %s\n", percentile(latencies, 95))
+  fmt.Printf("  max    %s\n", latencies[len(latencies) - 1])
+
+  return nil
+}
+
+// Nearest-rank percentile of an already sorted slice
+func percentile(sorted []time.Duration, p float64) time.Duration {
+  rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+  if rank < 1 {
+    rank = 1
+  }
+
+  return sorted[rank - 1]
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+  "fmt"
+  "io"
+  "strings"
+  "text/template"
+  "time"
+)
+
+// Fields available to -caption-template
+type captionData struct {
+  Namespace string
+  // The panel's title, i.e. its metric and whatever is drawn alongside it
+  Metric string
+  // Empty for series derived from several metrics
+  Stat string
+  Lookback time.Duration
+  End time.Time
+  Min float64
+  Max float64
+  // How values were rescaled by -normalize, e.g. "z-score", or empty
+  Normalized string
+}
+
+// Parse -caption-template, trying it on sample data so a reference to an unknown field fails now rather than on every render
+func parseCaptionTemplate(raw string) (*template.Template, error) {
+  caption, err := template.New("caption").Parse(raw)
+  if err != nil {
+    return nil, fmt.Errorf("invalid -caption-template: %w", err)
+  }
+  if err := caption.Execute(io.Discard, captionData{}); err != nil {
+    return nil, fmt.Errorf("invalid -caption-template: %w", err)
+  }
+
+  return caption, nil
+}
+
+// The panel's labels joined, naming everything drawn on it
+func (panel Panel) metricTitle() string {
+  title := panel.Label
+  for _, overlay := range panel.Overlays {
+    title += " vs " + overlay.Label
+  }
+
+  return title
+}
+
+// What the panel shows, for its caption: the namespaced metric title, unless the panel has its own title
+func (panel Panel) scope(namespace string) string {
+  if panel.Title != "" {
+    return panel.Title
+  }
+
+  return namespace + "/" + panel.metricTitle()
+}
+
+// The panel's caption, from its template if it has one
+func (panel Panel) caption(namespace string, lookback time.Duration, end time.Time) string {
+  if panel.Lookback != 0 {
+    lookback = panel.Lookback
+  }
+  title := panel.metricTitle()
+  combined := append(Series{}, panel.Series...)
+  for _, overlay := range panel.Overlays {
+    combined = append(combined, overlay.Series...)
+  }
+  scope := panel.scope(namespace)
+  if panel.CaptionTemplate == nil {
+    if panel.Normalized != "" {
+      scope += " as " + normalizations[panel.Normalized]
+    }
+    if !panel.WindowStart.IsZero() {
+      return fmt.Sprintf("[%s] from %s to %s", scope, formatTime(panel.WindowStart), formatTime(end))
+    }
+    return fmt.Sprintf("[%s] with lookback=%s (last updated at %s)", scope, lookback, formatTime(end))
+  }
+
+  data := captionData{ Namespace: namespace, Metric: title, Lookback: lookback, End: end, Normalized: normalizations[panel.Normalized] }
+  if panel.Query != nil {
+    data.Stat = panel.Query.Stat
+  }
+  data.Min, data.Max, _ = combined.Bounds()
+
+  var caption strings.Builder
+  if err := panel.CaptionTemplate.Execute(&caption, data); err != nil {
+    return fmt.Sprintf("[%s] caption template failed: %s", scope, err.Error())
+  }
+
+  return caption.String()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+  "bytes"
+  "fmt"
+  "io"
+  "os"
+  "os/exec"
+  "strings"
+)
+
+// Commands that copy their stdin to the clipboard, in the order they're tried: macOS, Wayland, X11, then Windows and WSL
+var clipboardCommands = [][]string{
+  { "pbcopy" },
+  { "wl-copy" },
+  { "xclip", "-selection", "clipboard" },
+  { "xsel", "--clipboard", "--input" },
+  { "clip.exe" },
+}
+
+// Everything printed to stdout while it's set, to be copied to the clipboard rather than shown
+type clipboardCapture struct {
+  command []string
+  stdout *os.File
+  writer *os.File
+  captured bytes.Buffer
+  done chan struct{}
+}
+
+// The first clipboard command on the PATH, or nil if there's none
+func findClipboard() []string {
+  for _, command := range clipboardCommands {
+    // wl-copy is installed alongside X11 tools on some desktops but only works in a Wayland session
+    if command[0] == "wl-copy" && os.Getenv("WAYLAND_DISPLAY") == "" {
+      continue
+    }
+    if _, err := exec.LookPath(command[0]); err == nil {
+      return command
+    }
+  }
+
+  return nil
+}
+
+// Start capturing stdout for -clipboard, or return nil to print as usual if it wasn't asked for or there's no clipboard command
+func startClipboard(options Options) *clipboardCapture {
+  if !options.clipboard {
+    return nil
+  }
+  command := findClipboard()
+  if command == nil {
+    warnf("Warning: -clipboard found none of pbcopy, wl-copy, xclip, xsel, or clip.exe on the PATH, so the output is printed instead")
+    return nil
+  }
+  capture, err := captureForClipboard(command)
+  if err != nil {
+    warnf("Warning: %s, so the output is printed instead", err.Error())
+    return nil
+  }
+
+  return capture
+}
+
+// Swap stdout for a pipe until finish, so every mode's output is captured without each having to know about -clipboard
+func captureForClipboard(command []string) (*clipboardCapture, error) {
+  reader, writer, err := os.Pipe()
+  if err != nil {
+    return nil, fmt.Errorf("cannot capture output for -clipboard: %w", err)
+  }
+
+  capture := &clipboardCapture{ command: command, stdout: os.Stdout, writer: writer, done: make(chan struct{}) }
+  go func () {
+    defer close(capture.done)
+    io.Copy(&capture.captured, reader)
+    reader.Close()
+  }()
+  os.Stdout = writer
+
+  return capture, nil
+}
+
+// Restore stdout and copy what was printed, without the escapes that clear the screen and color the graph.
+// If the copy fails the output is printed after all, so it isn't lost. Nothing to do without a capture.
+func (capture *clipboardCapture) finish() {
+  if capture == nil {
+    return
+  }
+  os.Stdout = capture.stdout
+  capture.writer.Close()
+  <-capture.done
+
+  output := stripEscapes(capture.captured.String())
+  cmd := exec.Command(capture.command[0], capture.command[1:]...)
+  cmd.Stdin = strings.NewReader(output)
+  if message, err := cmd.CombinedOutput(); err != nil {
+    warnf("Warning: %s failed (%s), so the output is printed instead: %s", capture.command[0], err.Error(), strings.TrimSpace(string(message)))
+    fmt.Print(output)
+    return
+  }
+  warnf("Copied %d line(s) to the clipboard with %s", strings.Count(output, "\n"), capture.command[0])
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+  "fmt"
+  "math"
+  "time"
+)
+
+// Values accepted by -compare-mode
+const (
+  compareOverlay = "overlay"
+  compareRatio = "ratio"
+  compareDelta = "delta"
+)
+
+// Fetch the query's series for [start, end) alongside the same window -compare earlier, combined according to -compare-mode
+func (client Client) fetchComparedPanel(options Options, query MetricQuery, start time.Time, end time.Time) (Panel, error) {
+  request := newMetricStatisticsRequest(query, start, end)
+  current, err := client.getMetricSampleCounts(&request, options.fillPolicy())
+  if err != nil {
+    return Panel{}, err
+  }
+
+  comparedRequest := newMetricStatisticsRequest(query, start.Add(-options.compare), end.Add(-options.compare))
+  compared, err := client.getMetricSampleCounts(&comparedRequest, options.fillPolicy())
+  if err != nil {
+    return Panel{}, fmt.Errorf("fetching the window %s earlier: %w", options.compare, err)
+  }
+  compared = compared.Shift(options.compare)
+
+  switch options.compareMode {
+  case compareRatio:
+    return Panel{ Label: fmt.Sprintf("%s %% change vs %s earlier", query.Label(), options.compare), Series: ratioSeries(current, compared) }, nil
+  case compareDelta:
+    delta, _ := diffSeries(current, compared)
+    return Panel{ Label: fmt.Sprintf("%s change vs %s earlier", query.Label(), options.compare), Series: delta }, nil
+  default:
+    return Panel{
+      Label: query.Label(),
+      Series: current,
+      Query: &query,
+      Overlays: []Panel{ { Label: fmt.Sprintf("%s earlier", options.compare), Series: compared } },
+    }, nil
+  }
+}
+
+// Percentage change of each bucket of current relative to compared. There's no meaningful change from zero, so those buckets become breaks.
+func ratioSeries(current Series, compared Series) Series {
+  comparedByTime := map[int64]Point{}
+  for _, point := range compared {
+    comparedByTime[point.Timestamp.Unix()] = point
+  }
+
+  ratio := Series{}
+  for _, point := range current {
+    other, ok := comparedByTime[point.Timestamp.Unix()]
+    if !ok {
+      continue
+    }
+
+    value := math.NaN()
+    if other.Value != 0 {
+      value = (point.Value / other.Value - 1) * 100
+    }
+    ratio = append(ratio, Point{ Timestamp: point.Timestamp, Value: value, Filled: point.Filled || other.Filled })
+  }
+
+  return ratio
+}
+
+// Fetch the query from both -compare-namespaces on shared axes, plus a panel of their difference so divergence stands out
+func (client Client) fetchNamespacePanels(options Options, query MetricQuery, start time.Time, end time.Time) ([]Panel, error) {
+  fetched := []Panel{}
+  for _, namespace := range options.compareNamespaces {
+    namespaced := query
+    namespaced.Namespace = namespace
+    request := newMetricStatisticsRequest(namespaced, start, end)
+    series, err := client.getMetricSampleCounts(&request, options.fillPolicy())
+    if err != nil {
+      return []Panel{}, err
+    }
+    fetched = append(fetched, Panel{ Label: namespace, Series: series, Query: &namespaced })
+  }
+
+  first, second := fetched[0], fetched[1]
+  first.Color, second.Color = "blue", "green"
+  first.Title = fmt.Sprintf("%s in %s vs %s", query.Label(), first.Label, second.Label)
+  first.Overlays = []Panel{ second }
+
+  delta, misaligned := diffSeries(first.Series, second.Series)
+  if misaligned > 0 {
+    warnf("Warning: %d bucket(s) of %s in %s and %s don't line up in time and were left out of the difference", misaligned, query.Label(), first.Label, second.Label)
+  }
+
+  return []Panel{ first, { Label: fmt.Sprintf("%s - %s", first.Label, second.Label), Series: delta, Title: fmt.Sprintf("%s in %s - %s", query.Label(), first.Label, second.Label) } }, nil
+}
+
+// Check the metric is published in both -compare-namespaces up front, since an empty window alone doesn't say which side is missing it
+func (client Client) checkNamespacesHaveMetric(options Options) error {
+  query := options.queries[0]
+  for _, namespace := range options.compareNamespaces {
+    metrics, err := client.listMetrics(namespace, query.Metric, query.Dimensions)
+    if err != nil {
+      return err
+    }
+    if len(metrics) == 0 {
+      return fmt.Errorf("%w: %s doesn't exist in namespace %s", ErrNoData, query.Label(), namespace)
+    }
+  }
+
+  return nil
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+  "time"
+)
+
+// Fetch the panel's metric at the second -compare-stat statistic and draw it over the first, on the same axis since both share the metric's unit.
+// Both are labelled by their statistic and colored like the -compare-metrics pair so the legend tells them apart.
+func (client Client) fetchComparedStat(options Options, panel Panel, start time.Time, end time.Time) (Panel, error) {
+  other := *panel.Query
+  other.Stat = options.compareStats[1]
+  request := newMetricStatisticsRequest(other, start, end)
+  series, err := client.getMetricSampleCounts(&request, options.fillPolicy())
+  if err != nil {
+    return panel, err
+  }
+
+  panel.Label, panel.Color = panel.Query.Label() + " " + panel.Query.Stat, firstMetricColor
+  panel.Overlays = append(panel.Overlays, Panel{ Label: other.Label() + " " + other.Stat, Series: series, Query: &other, Color: secondMetricColor })
+
+  return panel, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+  "fmt"
+  "math"
+)
+
+// Colors telling the two -compare-metrics series, or -compare-stat statistics, apart
+const (
+  firstMetricColor = "blue"
+  secondMetricColor = "orange"
+)
+
+// Draw the second -metric over the first on shared axes, so correlationSummary can describe them together in the footer
+func compareMetricsPanel(panels []Panel) Panel {
+  first, second := panels[0], panels[1]
+  first.Color, second.Color = firstMetricColor, secondMetricColor
+  first.Overlays = []Panel{ second }
+
+  return first
+}
+
+// Pearson's r between the series over the timestamps both have a real datapoint at, so gap-filled buckets and misaligned sampling don't count
+func correlation(a Series, b Series) (float64, int) {
+  byTime := map[int64]float64{}
+  for _, point := range b {
+    if !point.Filled && !math.IsNaN(point.Value) {
+      byTime[point.Timestamp.Unix()] = point.Value
+    }
+  }
+
+  xs, ys := []float64{}, []float64{}
+  for _, point := range a {
+    if y, ok := byTime[point.Timestamp.Unix()]; ok && !point.Filled && !math.IsNaN(point.Value) {
+      xs = append(xs, point.Value)
+      ys = append(ys, y)
+    }
+  }
+  if len(xs) < 2 {
+    return math.NaN(), len(xs)
+  }
+
+  meanX, meanY := sum(xs) / float64(len(xs)), sum(ys) / float64(len(ys))
+  var covariance, varianceX, varianceY float64
+  for i := range xs {
+    covariance += (xs[i] - meanX) * (ys[i] - meanY)
+    varianceX += (xs[i] - meanX) * (xs[i] - meanX)
+    varianceY += (ys[i] - meanY) * (ys[i] - meanY)
+  }
+  // A flat series doesn't vary with anything
+  if varianceX == 0 || varianceY == 0 {
+    return math.NaN(), len(xs)
+  }
+
+  return covariance / math.Sqrt(varianceX * varianceY), len(xs)
+}
+
+func correlationSummary(panel Panel) string {
+  other := panel.Overlays[0]
+  r, aligned := correlation(panel.Series, other.Series)
+  if math.IsNaN(r) {
+    return fmt.Sprintf("Correlation of %s and %s undefined over %d aligned datapoint(s)", panel.Label, other.Label, aligned)
+  }
+
+  return fmt.Sprintf("Correlation of %s and %s: r=%.2f over %d aligned datapoints", panel.Label, other.Label, r, aligned)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+  "fmt"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// The ticks of a five-field cron expression: minute, hour, day of month, month, and day of week, in local time as crontab has them.
+// Only what's needed to find ticks is supported: *, numbers, ranges, lists, and steps, but not names or macros like @hourly.
+type cronSchedule struct {
+  minutes, hours, days, months, weekdays map[int]bool
+  // As in cron, when both day fields are restricted a tick needs to match either of them
+  daysRestricted, weekdaysRestricted bool
+}
+
+// How far back previous looks for a tick before deciding the schedule never fires, e.g. for February 30th
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+func parseCron(expression string) (*cronSchedule, error) {
+  fields := strings.Fields(expression)
+  if len(fields) != 5 {
+    return nil, fmt.Errorf("cron expression %q needs 5 fields (minute hour day-of-month month day-of-week), but has %d", expression, len(fields))
+  }
+
+  schedule := &cronSchedule{}
+  var err error
+  if schedule.minutes, err = parseCronField(fields[0], "minute", 0, 59); err != nil {
+    return nil, err
+  }
+  if schedule.hours, err = parseCronField(fields[1], "hour", 0, 23); err != nil {
+    return nil, err
+  }
+  if schedule.days, err = parseCronField(fields[2], "day of month", 1, 31); err != nil {
+    return nil, err
+  }
+  if schedule.months, err = parseCronField(fields[3], "month", 1, 12); err != nil {
+    return nil, err
+  }
+  // 7 is Sunday too
+  if schedule.weekdays, err = parseCronField(fields[4], "day of week", 0, 7); err != nil {
+    return nil, err
+  }
+  if schedule.weekdays[7] {
+    schedule.weekdays[0] = true
+  }
+  schedule.daysRestricted, schedule.weekdaysRestricted = fields[2] != "*", fields[4] != "*"
+
+  if _, ok := schedule.previous(time.Now()); !ok {
+    return nil, fmt.Errorf("cron expression %q never matches", expression)
+  }
+
+  return schedule, nil
+}
+
+// The values a field matches, from a comma-separated list of *, N, or A-B, each optionally followed by /STEP
+func parseCronField(field string, name string, min int, max int) (map[int]bool, error) {
+  values := map[int]bool{}
+  for _, part := range strings.Split(field, ",") {
+    rangePart, step := part, 1
+    if i := strings.Index(part, "/"); i >= 0 {
+      parsed, err := strconv.Atoi(part[i + 1:])
+      if err != nil || parsed <= 0 {
+        return nil, fmt.Errorf("invalid step in cron %s %q", name, part)
+      }
+      rangePart, step = part[:i], parsed
+    }
+
+    from, to := min, max
+    if rangePart != "*" {
+      bounds := strings.SplitN(rangePart, "-", 2)
+      var err error
+      if from, err = strconv.Atoi(bounds[0]); err != nil {
+        return nil, fmt.Errorf("invalid cron %s %q", name, part)
+      }
+      to = from
+      if len(bounds) == 2 {
+        if to, err = strconv.Atoi(bounds[1]); err != nil {
+          return nil, fmt.Errorf("invalid cron %s %q", name, part)
+        }
+      } else if step > 1 {
+        // N/STEP runs from N to the end of the range
+        to = max
+      }
+    }
+    if from < min || to > max || from > to {
+      return nil, fmt.Errorf("cron %s %q is outside %d-%d", name, part, min, max)
+    }
+    for value := from; value <= to; value += step {
+      values[value] = true
+    }
+  }
+
+  return values, nil
+}
+
+// The latest tick at or before t. ok is false if there's none within cronSearchLimit.
+func (schedule *cronSchedule) previous(t time.Time) (time.Time, bool) {
+  // Every tick is on a whole minute, and jumping back a whole field at a time keeps this to a few thousand steps even for yearly schedules
+  tick := t.Truncate(time.Minute)
+  for t.Sub(tick) <= cronSearchLimit {
+    year, month, day := tick.Date()
+    hour, location := tick.Hour(), tick.Location()
+    switch {
+    case !schedule.months[int(month)]:
+      tick = time.Date(year, month, 1, 0, 0, 0, 0, location).Add(-time.Minute)
+    case !schedule.matchesDay(tick):
+      tick = time.Date(year, month, day, 0, 0, 0, 0, location).Add(-time.Minute)
+    case !schedule.hours[hour]:
+      tick = time.Date(year, month, day, hour, 0, 0, 0, location).Add(-time.Minute)
+    case !schedule.minutes[tick.Minute()]:
+      tick = tick.Add(-time.Minute)
+    default:
+      return tick, true
+    }
+  }
+
+  return time.Time{}, false
+}
+
+func (schedule *cronSchedule) matchesDay(t time.Time) bool {
+  day, weekday := schedule.days[t.Day()], schedule.weekdays[int(t.Weekday())]
+  if schedule.daysRestricted && schedule.weekdaysRestricted {
+    return day || weekday
+  }
+
+  return day && weekday
+}
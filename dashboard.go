@@ -0,0 +1,202 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "os"
+  "strings"
+  "time"
+
+  "github.com/guptarohit/asciigraph"
+)
+
+// A saved view for -dashboard: panels stacked vertically, each drawing one or more series on shared axes. Series on one panel should share a period, or their buckets won't line up.
+type dashboardFile struct {
+  Panels []dashboardFilePanel `json:"panels"`
+}
+
+type dashboardFilePanel struct {
+  Title string `json:"title"`
+  Series []dashboardFileSeries `json:"series"`
+}
+
+type dashboardFileSeries struct {
+  Metric string `json:"metric"`
+  // Default to -namespace and -stat
+  Namespace string `json:"namespace"`
+  Stat string `json:"stat"`
+  // Seconds, defaulting to -period
+  Period int64 `json:"period"`
+  // Name=Value, as for -dimension
+  Dimensions []string `json:"dimensions"`
+  Label string `json:"label"`
+  Color string `json:"color"`
+}
+
+// A few of the color names asciigraph knows, for error messages. The full palette is the X11 color names.
+const paletteExamples = "red, green, blue, yellow, cyan, magenta, orange, or gray"
+
+// A dashboard panel ready to fetch
+type dashboardPanel struct {
+  title string
+  series []dashboardSeries
+}
+
+type dashboardSeries struct {
+  query MetricQuery
+  label string
+  color string
+}
+
+// Read a dashboard file, or a Grafana dashboard export, filling in what each series leaves out from the command line
+func loadDashboard(path string, options Options) ([]dashboardPanel, error) {
+  raw, err := os.ReadFile(path)
+  if err != nil {
+    return nil, err
+  }
+  file := dashboardFile{}
+  if isGrafanaDashboard(raw) {
+    file, err = importGrafanaDashboard(raw)
+  } else {
+    err = json.Unmarshal(raw, &file)
+  }
+  if err != nil {
+    return nil, fmt.Errorf("%s: %w", path, err)
+  }
+  if len(file.Panels) == 0 {
+    return nil, fmt.Errorf("%s: no panels", path)
+  }
+
+  panels := []dashboardPanel{}
+  for i, filePanel := range file.Panels {
+    if len(filePanel.Series) == 0 {
+      return nil, fmt.Errorf("%s: panel %d has no series", path, i + 1)
+    }
+
+    panel := dashboardPanel{ title: filePanel.Title }
+    for _, fileSeries := range filePanel.Series {
+      if fileSeries.Metric == "" {
+        return nil, fmt.Errorf("%s: panel %d has a series without a metric", path, i + 1)
+      }
+      dimensions, err := parseDimensions(fileSeries.Dimensions)
+      if err != nil {
+        return nil, fmt.Errorf("%s: %w", path, err)
+      }
+      query := MetricQuery{
+        Metric: fileSeries.Metric,
+        Namespace: options.namespace,
+        Dimensions: dimensions,
+        Period: options.period,
+        Stat: options.stat,
+      }
+      if fileSeries.Namespace != "" {
+        query.Namespace = fileSeries.Namespace
+      }
+      if fileSeries.Stat != "" {
+        query.Stat = fileSeries.Stat
+      }
+      if fileSeries.Period != 0 {
+        query.Period = fileSeries.Period
+      }
+      if !validPeriod(query.Period) {
+        return nil, fmt.Errorf("%s: invalid period %d for %s, CloudWatch accepts 1, 5, 10, 30, or a multiple of 60", path, query.Period, query.Metric)
+      }
+      if !validStat(query.Stat) {
+        return nil, fmt.Errorf("%s: unknown stat %q for %s", path, query.Stat, query.Metric)
+      }
+      color := strings.ToLower(fileSeries.Color)
+      if _, ok := asciigraph.ColorNames[color]; color != "" && !ok {
+        return nil, fmt.Errorf("%s: unknown color %q for %s, expected a name like %s", path, fileSeries.Color, query.Metric, paletteExamples)
+      }
+
+      series := dashboardSeries{ query: query, label: fileSeries.Label, color: color }
+      if series.label == "" {
+        series.label = query.Label()
+      }
+      panel.series = append(panel.series, series)
+    }
+    panels = append(panels, panel)
+  }
+
+  return panels, nil
+}
+
+// Fetch each dashboard panel
+func (client Client) fetchDashboardPanels(options Options, start time.Time, end time.Time) ([]Panel, error) {
+  panels := []Panel{}
+  for _, dashboard := range options.dashboardPanels {
+    panel, err := client.fetchDashboardPanel(options, dashboard, start, end)
+    if err != nil {
+      return panels, err
+    }
+    panels = append(panels, panel)
+  }
+
+  return panels, nil
+}
+
+// Fetch a dashboard panel, its first series as the panel and the rest as overlays
+func (client Client) fetchDashboardPanel(options Options, dashboard dashboardPanel, start time.Time, end time.Time) (Panel, error) {
+  fetched := []Panel{}
+  for _, series := range dashboard.series {
+    request := newMetricStatisticsRequest(series.query, start, end)
+    points, err := client.getMetricSampleCounts(&request, options.fillPolicy())
+    if err != nil {
+      return Panel{}, err
+    }
+    query := series.query
+    fetched = append(fetched, Panel{ Label: series.label, Series: points, Query: &query, Color: series.color })
+  }
+
+  panel := fetched[0]
+  panel.Title = dashboard.title
+  panel.Overlays = fetched[1:]
+  return panel, nil
+}
+
+// How often a dashboard panel is polled while tailing: as often as its finest series gets a new bucket
+func (dashboard dashboardPanel) period() int64 {
+  period := dashboard.series[0].query.Period
+  for _, series := range dashboard.series {
+    if series.query.Period < period {
+      period = series.query.Period
+    }
+  }
+
+  return period
+}
+
+// The panel itself followed by its overlays, in the order they're drawn
+func (panel Panel) allSeries() []Panel {
+  return append([]Panel{ panel }, panel.Overlays...)
+}
+
+func (panel Panel) hasColors() bool {
+  for _, series := range panel.allSeries() {
+    if series.Color != "" {
+      return true
+    }
+  }
+
+  return false
+}
+
+func (panel Panel) colors() []asciigraph.AnsiColor {
+  colors := []asciigraph.AnsiColor{}
+  for _, series := range panel.allSeries() {
+    colors = append(colors, asciigraph.ColorNames[series.Color])
+  }
+
+  return colors
+}
+
+// A line naming each series in its color, since the caption can't be colored per series
+func (panel Panel) legend() string {
+  entries := []string{}
+  colors := panel.colors()
+  for i, series := range panel.allSeries() {
+    entries = append(entries, fmt.Sprintf("%s── %s%s", colors[i], series.Label, asciigraph.Default))
+  }
+
+  return " " + strings.Join(entries, "   ")
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+  "fmt"
+  "os"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// Read the deploy time CI wrote to the -since-deploy file, as RFC 3339 or Unix seconds
+func readDeployMarker(path string) (time.Time, error) {
+  raw, err := os.ReadFile(path)
+  if err != nil {
+    return time.Time{}, fmt.Errorf("cannot read -since-deploy file: %w", err)
+  }
+
+  text := strings.TrimSpace(string(raw))
+  deployedAt, err := parseTimestamp(text)
+  if err != nil {
+    return time.Time{}, fmt.Errorf("%s: %w", path, err)
+  }
+  if !deployedAt.Before(time.Now()) {
+    return time.Time{}, fmt.Errorf("%w: deploy time %s in %s is in the future", ErrInvalidWindow, deployedAt, path)
+  }
+
+  return deployedAt, nil
+}
+
+// Timestamps in files CI writes, as RFC 3339 or Unix seconds
+func parseTimestamp(text string) (time.Time, error) {
+  if timestamp, err := time.Parse(time.RFC3339, text); err == nil {
+    return timestamp, nil
+  }
+  seconds, err := strconv.ParseInt(text, 10, 64)
+  if err != nil {
+    return time.Time{}, fmt.Errorf("expected an RFC 3339 timestamp or Unix seconds, got %q", text)
+  }
+
+  return time.Unix(seconds, 0), nil
+}
+
+// The deploy -since-deploy starts the window at, drawn as a column like the -events
+func deployEvent(deployedAt time.Time) Event {
+  return Event{ At: deployedAt, Label: "deploy" }
+}
+
+// How long ago the deploy was, which the event's label leaves out
+func deploySummary(deployedAt time.Time, end time.Time) string {
+  return fmt.Sprintf("│ origin: deploy at %s (%s ago)", formatTime(deployedAt), end.Sub(deployedAt).Round(time.Second))
+}
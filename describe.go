@@ -0,0 +1,104 @@
+package main
+
+import (
+  "fmt"
+  "sort"
+  "strings"
+  "time"
+
+  "github.com/aws/aws-sdk-go/aws"
+)
+
+// What common AWS metrics mean, by namespace then metric. Add entries here as they come up.
+var metricDescriptions = map[string]map[string]string{
+  "AWS/Lambda": {
+    "Invocations": "Number of times the function was invoked, including failed invocations but not throttled ones",
+    "Errors": "Invocations that failed due to an error in the function",
+    "Throttles": "Invocation requests rejected because of concurrency limits",
+    "Duration": "Milliseconds the function code spent processing an event",
+    "ConcurrentExecutions": "Function instances processing events at the same time",
+  },
+  "AWS/SQS": {
+    "ApproximateNumberOfMessagesVisible": "Messages available for retrieval from the queue",
+    "ApproximateAgeOfOldestMessage": "Seconds the oldest message not yet deleted has been in the queue",
+    "NumberOfMessagesSent": "Messages added to the queue",
+    "NumberOfMessagesDeleted": "Messages deleted from the queue",
+  },
+  "AWS/DynamoDB": {
+    "ConsumedReadCapacityUnits": "Read capacity units consumed over the period",
+    "ConsumedWriteCapacityUnits": "Write capacity units consumed over the period",
+    "ThrottledRequests": "Requests exceeding the provisioned throughput limits",
+    "SuccessfulRequestLatency": "Milliseconds taken by successful requests",
+  },
+  "AWS/EC2": {
+    "CPUUtilization": "Percentage of allocated compute units in use on the instance",
+    "NetworkIn": "Bytes received on all network interfaces",
+    "NetworkOut": "Bytes sent out on all network interfaces",
+  },
+  "AWS/ApplicationELB": {
+    "RequestCount": "Requests processed over IPv4 and IPv6",
+    "TargetResponseTime": "Seconds from the request leaving the load balancer until a response from the target",
+    "HTTPCode_Target_5XX_Count": "HTTP 5XX responses generated by the targets",
+  },
+}
+
+// Most dimension combinations listed per metric
+const describeDimensionSets = 20
+
+// Print what's knowable about each queried metric: what it means, the dimensions it's published with, its unit, and how much of the window has data
+func (client Client) describe(options Options) error {
+  end := time.Now()
+  start := end.Add(options.lookback)
+  for i, query := range options.queries {
+    if i > 0 {
+      fmt.Println()
+    }
+    fmt.Printf("%s/%s\n", query.Namespace, query.Metric)
+    if description, ok := metricDescriptions[query.Namespace][query.Metric]; ok {
+      fmt.Printf("  %s\n", description)
+    }
+
+    metrics, err := client.listMetrics(query.Namespace, query.Metric, query.Dimensions)
+    if err != nil {
+      return err
+    }
+    sets := []string{}
+    for _, metric := range metrics {
+      pairs := []string{}
+      for _, dimension := range metric.Dimensions {
+        pairs = append(pairs, aws.StringValue(dimension.Name) + "=" + aws.StringValue(dimension.Value))
+      }
+      sets = append(sets, strings.Join(pairs, ","))
+    }
+    sort.Strings(sets)
+    fmt.Printf("  Dimensions: %d combination(s)\n", len(sets))
+    for j, set := range sets {
+      if j == describeDimensionSets {
+        fmt.Printf("    ... and %d more\n", len(sets) - j)
+        break
+      }
+      if set == "" {
+        set = "(none)"
+      }
+      fmt.Printf("    %s\n", set)
+    }
+
+    request := newMetricStatisticsRequest(query, start, end)
+    datapoints, period, err := client.sendGetMetricStatisticsRequest(&request)
+    if err != nil {
+      return err
+    }
+    expected := int(-options.lookback / (time.Duration(period) * time.Second))
+    if len(datapoints) == 0 {
+      fmt.Printf("  Data: none of %d buckets in the last %s\n", expected, -options.lookback)
+      continue
+    }
+    sort.Slice(datapoints, func (a, b int) bool {
+      return datapoints[a].Timestamp.Before(*datapoints[b].Timestamp)
+    })
+    fmt.Printf("  Unit: %s\n", aws.StringValue(datapoints[0].Unit))
+    fmt.Printf("  Data: %d of %d buckets in the last %s, from %s to %s\n", len(datapoints), expected, -options.lookback, formatTime(*datapoints[0].Timestamp), formatTime(*datapoints[len(datapoints) - 1].Timestamp))
+  }
+
+  return nil
+}
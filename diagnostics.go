@@ -0,0 +1,37 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "os"
+)
+
+// Set by -quiet. Warnings and status messages are dropped; errors are still reported.
+var quiet bool
+
+// Diagnostics always go to stderr so stdout only ever carries the requested output
+func warnf(format string, args ...interface{}) {
+  if quiet {
+    return
+  }
+  fmt.Fprintf(os.Stderr, format + "\n", args...)
+}
+
+func errorln(args ...interface{}) {
+  fmt.Fprintln(os.Stderr, args...)
+}
+
+// Detail asked for with -info. Still on stderr, but never silenced by -quiet.
+func infof(format string, args ...interface{}) {
+  fmt.Fprintf(os.Stderr, format + "\n", args...)
+}
+
+// Raw SDK output asked for with -dump-response, as indented JSON on stderr
+func dumpResponse(output interface{}) {
+  raw, err := json.MarshalIndent(output, "", "  ")
+  if err != nil {
+    errorln("Cannot dump response:", err.Error())
+    return
+  }
+  fmt.Fprintln(os.Stderr, string(raw))
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+  "fmt"
+  "path"
+  "sort"
+  "strings"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/cloudwatch"
+)
+
+// Glob syntax accepted in -metric, as understood by path.Match
+func isGlob(pattern string) bool {
+  return strings.ContainsAny(pattern, "*?[")
+}
+
+func (options Options) hasGlob() bool {
+  for _, query := range options.queries {
+    if isGlob(query.Metric) {
+      return true
+    }
+  }
+
+  return false
+}
+
+// Expand -stack and glob queries into concrete ones. Either can produce several series, which are then stacked unless they're being subtracted or combined by -expression.
+func (client Client) resolveQueries(options Options) (Options, error) {
+  var err error
+  if options.stack != "" {
+    options, err = client.expandStackQueries(options)
+    if err != nil {
+      return options, err
+    }
+  }
+
+  if options.groupBy != "" {
+    return client.expandGroupBy(options)
+  }
+
+  // -top expands the queries itself on every refresh
+  if options.top || (!options.hasGlob() && options.namespacePrefix == "") {
+    return options, nil
+  }
+
+  options.queries, err = client.expandQueries(options.queries)
+  if err != nil {
+    return options, err
+  }
+  if options.namespacePrefix != "" {
+    // The matches can come from several namespaces, so captions name the prefix instead
+    options.namespace = options.namespacePrefix + "*"
+  }
+  if len(options.queries) == 0 {
+    return options, fmt.Errorf("%w: no metrics in %s match %s", ErrNoData, options.namespace, options.metrics.String())
+  }
+  if options.diff && len(options.queries) != 2 {
+    return options, fmt.Errorf("-diff requires exactly two metrics, but the globs matched %d", len(options.queries))
+  }
+  options.stacked = !options.diff && options.expression == ""
+
+  return options, nil
+}
+
+// List every metric in the namespace carrying the given dimensions, or in every namespace if it's empty. A non-empty metric narrows the listing to that name.
+func (client Client) listMetrics(namespace string, metric string, dimensions []*cloudwatch.Dimension) ([]*cloudwatch.Metric, error) {
+  cachePath := ""
+  if client.discoveryCacheTTL > 0 {
+    account, err := client.accountID()
+    path := ""
+    if err == nil {
+      path, err = discoveryCachePath(account, *client.connection.Config.Region, namespace, metric, dimensions, client.includeLinkedAccounts, client.recentlyActive)
+    }
+    if err != nil {
+      warnf("Warning: discovery cache unavailable: %s", err.Error())
+    }
+    cachePath = path
+  }
+  if cachePath != "" && !client.noCache {
+    if metrics, ok := readDiscoveryCache(cachePath, client.discoveryCacheTTL); ok {
+      return metrics, nil
+    }
+  }
+
+  input := cloudwatch.ListMetricsInput{}
+  if namespace != "" {
+    input.Namespace = &namespace
+  }
+  if metric != "" {
+    input.MetricName = &metric
+  }
+  if client.includeLinkedAccounts {
+    input.IncludeLinkedAccounts = aws.Bool(true)
+  }
+  if client.recentlyActive {
+    input.RecentlyActive = aws.String(cloudwatch.RecentlyActivePt3h)
+  }
+  for _, dimension := range dimensions {
+    input.Dimensions = append(input.Dimensions, &cloudwatch.DimensionFilter{ Name: dimension.Name, Value: dimension.Value })
+  }
+
+  metrics := []*cloudwatch.Metric{}
+  err := client.connection.ListMetricsPages(&input, func (page *cloudwatch.ListMetricsOutput, lastPage bool) bool {
+    metrics = append(metrics, page.Metrics...)
+    return true
+  })
+  if err != nil {
+    return metrics, classifyAWSError(err)
+  }
+  if cachePath != "" {
+    writeDiscoveryCache(cachePath, metrics)
+  }
+
+  return metrics, nil
+}
+
+// Replace each query with one per metric and dimension combination in ListMetrics that it matches. Metric names may be globs.
+// With -namespace-prefix, every namespace is listed and those not starting with the prefix are filtered out here, since ListMetrics can only match a namespace exactly.
+func (client Client) expandQueries(queries []MetricQuery) ([]MetricQuery, error) {
+  expanded := []MetricQuery{}
+  for _, query := range queries {
+    name := query.Metric
+    if isGlob(name) {
+      name = ""
+    }
+    namespace := query.Namespace
+    if client.namespacePrefix != "" {
+      namespace = ""
+    }
+
+    metrics, err := client.listMetrics(namespace, name, query.Dimensions)
+    if err != nil {
+      return expanded, err
+    }
+    for _, metric := range metrics {
+      if matched, _ := path.Match(query.Metric, *metric.MetricName); !matched {
+        continue
+      }
+      if !strings.HasPrefix(*metric.Namespace, client.namespacePrefix) {
+        continue
+      }
+      match := query
+      match.Metric, match.Namespace, match.Dimensions = *metric.MetricName, *metric.Namespace, metric.Dimensions
+      expanded = append(expanded, match)
+    }
+  }
+
+  return expanded, nil
+}
+
+// Replace each query with one per value of the -group-by dimension it's published with, up to -group-by-limit of them.
+// GetMetricStatistics only matches exact dimension sets, so only metrics with the query's dimensions plus the grouped one count.
+func (client Client) expandGroupBy(options Options) (Options, error) {
+  queries := []MetricQuery{}
+  for _, query := range options.queries {
+    metrics, err := client.listMetrics(query.Namespace, query.Metric, query.Dimensions)
+    if err != nil {
+      return options, err
+    }
+
+    values := []string{}
+    partial := false
+    for _, metric := range metrics {
+      for _, dimension := range metric.Dimensions {
+        if *dimension.Name != options.groupBy {
+          continue
+        }
+        if len(metric.Dimensions) != len(query.Dimensions) + 1 {
+          partial = true
+          continue
+        }
+        values = append(values, *dimension.Value)
+      }
+    }
+    if len(values) == 0 {
+      if partial {
+        return options, fmt.Errorf("%w: %s is only published with %s alongside other dimensions; narrow it down with -dimension", ErrNoData, query.Label(), options.groupBy)
+      }
+      return options, fmt.Errorf("%w: %s isn't published with a %s dimension", ErrNoData, query.Label(), options.groupBy)
+    }
+
+    sort.Strings(values)
+    if len(values) > options.groupByLimit {
+      warnf("Warning: %s has %d values of %s, only graphing the first %d (see -group-by-limit)", query.Label(), len(values), options.groupBy, options.groupByLimit)
+      values = values[:options.groupByLimit]
+    }
+    for _, value := range values {
+      grouped := query
+      grouped.Dimensions = withDimension(query.Dimensions, options.groupBy, value)
+      queries = append(queries, grouped)
+    }
+  }
+  options.queries = queries
+  options.stacked = true
+
+  return options, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+  "crypto/sha256"
+  "encoding/hex"
+  "encoding/json"
+  "os"
+  "path/filepath"
+  "strings"
+  "time"
+
+  "github.com/aws/aws-sdk-go/service/cloudwatch"
+)
+
+// A cached ListMetrics result
+type discoveryCacheEntry struct {
+  Fetched time.Time
+  Metrics []*cloudwatch.Metric
+}
+
+// A file per account, region, namespace, and filter, under the user's cache directory. Other credentials in the same region can see a different account's metrics.
+func discoveryCachePath(account string, region string, namespace string, metric string, dimensions []*cloudwatch.Dimension, includeLinkedAccounts bool, recentlyActive bool) (string, error) {
+  cacheDir, err := os.UserCacheDir()
+  if err != nil {
+    return "", err
+  }
+
+  key := []string{ account, region, namespace, metric }
+  for _, dimension := range dimensions {
+    key = append(key, *dimension.Name + "=" + *dimension.Value)
+  }
+  // Listing linked accounts returns more metrics, so it mustn't share a cache entry with the plain listing
+  if includeLinkedAccounts {
+    key = append(key, "linked")
+  }
+  // Nor can the recently active subset stand in for the full listing, or the other way round
+  if recentlyActive {
+    key = append(key, "recent")
+  }
+  sum := sha256.Sum256([]byte(strings.Join(key, "\x00")))
+
+  return filepath.Join(cacheDir, "cw-top", "discovery", hex.EncodeToString(sum[:]) + ".json"), nil
+}
+
+// The cached metrics, if there are any younger than the TTL
+func readDiscoveryCache(path string, ttl time.Duration) ([]*cloudwatch.Metric, bool) {
+  contents, err := os.ReadFile(path)
+  if err != nil {
+    return nil, false
+  }
+
+  entry := discoveryCacheEntry{}
+  if err := json.Unmarshal(contents, &entry); err != nil || time.Now().Sub(entry.Fetched) > ttl {
+    return nil, false
+  }
+
+  return entry.Metrics, true
+}
+
+// Caching is best effort, so failures are only warned about
+func writeDiscoveryCache(path string, metrics []*cloudwatch.Metric) {
+  contents, err := json.Marshal(discoveryCacheEntry{ Fetched: time.Now(), Metrics: metrics })
+  if err == nil {
+    err = os.MkdirAll(filepath.Dir(path), 0700)
+  }
+  if err == nil {
+    err = os.WriteFile(path, contents, 0600)
+  }
+  if err != nil {
+    warnf("Warning: failed to cache discovery results: %s", err.Error())
+  }
+}
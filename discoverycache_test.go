@@ -0,0 +1,14 @@
+package main
+
+import "testing"
+
+func TestDiscoveryCachePathPerAccount(t *testing.T) {
+  first, err := discoveryCachePath("111111111111", "us-east-1", "AWS/Lambda", "", nil, false, false)
+  if err != nil {
+    t.Skip("no user cache directory:", err)
+  }
+  second, _ := discoveryCachePath("222222222222", "us-east-1", "AWS/Lambda", "", nil, false, false)
+  if first == second {
+    t.Errorf("two accounts share the cache entry %s", first)
+  }
+}
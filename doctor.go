@@ -0,0 +1,79 @@
+package main
+
+import (
+  "fmt"
+  "os"
+
+  "github.com/aws/aws-sdk-go/service/cloudwatch"
+  "github.com/aws/aws-sdk-go/service/sts"
+  "golang.org/x/crypto/ssh/terminal"
+)
+
+// Outcome of one -doctor check
+type check struct {
+  name string
+  err error
+  detail string
+}
+
+// Check the usual setup problems one by one, printing a pass/fail line for each
+func (client Client) doctor(options Options) error {
+  checks := []check{
+    client.checkCredentials(),
+    checkRegion(options),
+    client.checkCloudWatchAccess(options),
+    checkTerminal(),
+  }
+
+  failed := 0
+  for _, result := range checks {
+    if result.err != nil {
+      failed++
+      fmt.Printf("[FAIL] %s: %s\n", result.name, result.err.Error())
+    } else {
+      fmt.Printf("[PASS] %s: %s\n", result.name, result.detail)
+    }
+  }
+  if failed > 0 {
+    return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+  }
+
+  return nil
+}
+
+func (client Client) checkCredentials() check {
+  output, err := sts.New(client.session).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+  if err != nil {
+    return check{ name: "credentials", err: classifyAWSError(err) }
+  }
+
+  return check{ name: "credentials", detail: "authenticated as " + *output.Arn }
+}
+
+func checkRegion(options Options) check {
+  region, source := regionWithSource(options)
+  if source == "" {
+    return check{ name: "region", err: fmt.Errorf("not set; pass -region or set AWS_REGION (would default to %s)", region) }
+  }
+
+  return check{ name: "region", detail: fmt.Sprintf("%s (from %s)", region, source) }
+}
+
+// A single page of ListMetrics is the cheapest call that proves CloudWatch is reachable and readable
+func (client Client) checkCloudWatchAccess(options Options) check {
+  _, err := client.connection.ListMetrics(&cloudwatch.ListMetricsInput{ Namespace: &options.namespace })
+  if err != nil {
+    return check{ name: "CloudWatch access", err: classifyAWSError(err) }
+  }
+
+  return check{ name: "CloudWatch access", detail: "ListMetrics on " + options.namespace + " succeeded" }
+}
+
+func checkTerminal() check {
+  width, height, err := terminal.GetSize(int(os.Stdin.Fd()))
+  if err != nil {
+    return check{ name: "terminal size", err: fmt.Errorf("can't detect it (%s); graphs need a terminal, other -output formats don't", err.Error()) }
+  }
+
+  return check{ name: "terminal size", detail: fmt.Sprintf("%dx%d", width, height) }
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+  "fmt"
+  "math"
+  "strconv"
+)
+
+// For -dual-axis: rescale each overlay onto the panel's own range, so a series in different units keeps its shape instead of flattening against the axis.
+// asciigraph only draws one axis, so the overlay's real range goes in its label, which the caption and legend show.
+func dualAxisPanel(panel Panel) Panel {
+  low, high, ok := panel.Series.Bounds()
+  if !ok {
+    return panel
+  }
+
+  overlays := make([]Panel, len(panel.Overlays))
+  for i, overlay := range panel.Overlays {
+    overlayLow, overlayHigh, ok := overlay.Series.Bounds()
+    if !ok {
+      overlays[i] = overlay
+      continue
+    }
+
+    scaled := make(Series, len(overlay.Series))
+    for j, point := range overlay.Series {
+      if !math.IsNaN(point.Value) {
+        // A flat overlay has no range to stretch, so it's drawn along the bottom
+        fraction := 0.0
+        if overlayHigh > overlayLow {
+          fraction = (point.Value - overlayLow) / (overlayHigh - overlayLow)
+        }
+        point.Value = low + fraction * (high - low)
+      }
+      scaled[j] = point
+    }
+    overlay.Series = scaled
+    overlay.Label = fmt.Sprintf("%s (own axis %s to %s)", overlay.Label, strconv.FormatFloat(overlayLow, 'g', 4, 64), strconv.FormatFloat(overlayHigh, 'g', 4, 64))
+    overlays[i] = overlay
+  }
+  panel.Overlays = overlays
+
+  return panel
+}
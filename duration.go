@@ -0,0 +1,68 @@
+package main
+
+import (
+  "fmt"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// Units time.ParseDuration doesn't know about
+var longUnits = map[string]time.Duration{
+  "d": 24 * time.Hour,
+  "w": 7 * 24 * time.Hour,
+}
+
+// Like time.ParseDuration, but also accepts days (d) and weeks (w), alone or mixed with the standard units, e.g. -1d12h
+func parseDuration(raw string) (time.Duration, error) {
+  sign := time.Duration(1)
+  rest := raw
+  if strings.HasPrefix(rest, "-") || strings.HasPrefix(rest, "+") {
+    if rest[0] == '-' {
+      sign = -1
+    }
+    rest = rest[1:]
+  }
+  if rest == "" {
+    return 0, fmt.Errorf("invalid duration %q", raw)
+  }
+  if rest == "0" {
+    return 0, nil
+  }
+
+  total := time.Duration(0)
+  for rest != "" {
+    // Each component is a number followed by a unit
+    numberEnd := strings.IndexFunc(rest, func (r rune) bool {
+      return (r < '0' || r > '9') && r != '.'
+    })
+    if numberEnd <= 0 {
+      return 0, fmt.Errorf("invalid duration %q", raw)
+    }
+    unitEnd := strings.IndexFunc(rest[numberEnd:], func (r rune) bool {
+      return (r >= '0' && r <= '9') || r == '.'
+    })
+    if unitEnd == -1 {
+      unitEnd = len(rest) - numberEnd
+    }
+    number, unit := rest[:numberEnd], rest[numberEnd:numberEnd + unitEnd]
+    rest = rest[numberEnd + unitEnd:]
+
+    if size, ok := longUnits[unit]; ok {
+      value, err := strconv.ParseFloat(number, 64)
+      if err != nil {
+        return 0, fmt.Errorf("invalid duration %q", raw)
+      }
+      total += time.Duration(value * float64(size))
+      continue
+    }
+
+    component, err := time.ParseDuration(number + unit)
+    if err != nil {
+      return 0, fmt.Errorf("invalid duration %q: unknown unit %q (expected ns, us, ms, s, m, h, d, or w)", raw, unit)
+    }
+    total += component
+  }
+
+  return sign * total, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+  "testing"
+  "time"
+)
+
+func TestParseDuration(t *testing.T) {
+  day := 24 * time.Hour
+  cases := []struct {
+    raw string
+    want time.Duration
+    wantErr bool
+  }{
+    { raw: "-3d", want: -3 * day },
+    { raw: "-2w", want: -14 * day },
+    { raw: "-1d12h", want: -36 * time.Hour },
+    { raw: "90m", want: 90 * time.Minute },
+    { raw: "+1.5d", want: 36 * time.Hour },
+    { raw: "0", want: 0 },
+    { raw: "-3y", wantErr: true },
+    { raw: "-", wantErr: true },
+    { raw: "d", wantErr: true },
+  }
+
+  for _, c := range cases {
+    got, err := parseDuration(c.raw)
+    if c.wantErr {
+      if err == nil {
+        t.Errorf("parseDuration(%q) = %s, want an error", c.raw, got)
+      }
+      continue
+    }
+    if err != nil {
+      t.Errorf("parseDuration(%q) returned %v", c.raw, err)
+      continue
+    }
+    if got != c.want {
+      t.Errorf("parseDuration(%q) = %s, want %s", c.raw, got, c.want)
+    }
+  }
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+  "fmt"
+  "time"
+)
+
+// Fetch the panels as usual for -fail-on-empty, failing with ErrNoData if any came back with only gap-filled buckets.
+// Fetching a metric CloudWatch returns nothing for already fails, so this is for panels derived from several series, which don't go through that check.
+func (client Client) fetchNonEmptyPanels(options Options, start time.Time, end time.Time) ([]Panel, error) {
+  options.failOnEmpty = false
+  panels, err := client.fetchPanels(options, start, end)
+  if err != nil {
+    return panels, err
+  }
+  for _, panel := range panels {
+    if err := requireData(panel.Label, panel.Series, start, end); err != nil {
+      return panels, err
+    }
+  }
+
+  return panels, nil
+}
+
+// ErrNoData unless the series has at least one datapoint CloudWatch returned
+func requireData(label string, series Series, start time.Time, end time.Time) error {
+  if _, ok := series.Last(); !ok {
+    return fmt.Errorf("%w for %s between %s and %s, only gap-filled buckets (-fail-on-empty)", ErrNoData, label, formatTime(start), formatTime(end))
+  }
+
+  return nil
+}
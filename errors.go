@@ -0,0 +1,121 @@
+package main
+
+import (
+  "errors"
+  "fmt"
+  "strings"
+
+  "github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// Errors returned from the fetch path. They're wrapped with context, so compare with errors.Is.
+var (
+  ErrNoData = errors.New("no datapoints returned")
+  ErrThrottled = errors.New("request throttled by CloudWatch")
+  ErrBadCredentials = errors.New("AWS credentials are missing, expired, or invalid")
+  ErrInvalidWindow = errors.New("invalid time window")
+  ErrAccessDenied = errors.New("access denied")
+  // Not a failure as such: -alert-above was breached
+  ErrAlert = errors.New("alert threshold breached")
+  // -regression-threshold was exceeded
+  ErrRegression = errors.New("regression against baseline")
+  // -detect-flatline found an unchanging series, with -fail-on-flatline
+  ErrFlatline = errors.New("metric flatlined")
+)
+
+// Process exit codes for each class of error
+const (
+  exitOK = 0
+  exitFailure = 1
+  exitNoData = 2
+  exitThrottled = 3
+  exitBadCredentials = 4
+  exitInvalidWindow = 5
+  exitAccessDenied = 6
+  exitAlert = 7
+  exitRegression = 8
+  exitFlatline = 9
+)
+
+var throttlingCodes = map[string]bool{
+  "Throttling": true,
+  "ThrottlingException": true,
+  "RequestLimitExceeded": true,
+  "TooManyRequestsException": true,
+}
+
+var credentialCodes = map[string]bool{
+  "NoCredentialProviders": true,
+  "ExpiredToken": true,
+  "ExpiredTokenException": true,
+  "InvalidClientTokenId": true,
+  "UnrecognizedClientException": true,
+  "SignatureDoesNotMatch": true,
+  "InvalidSignatureException": true,
+}
+
+var accessDeniedCodes = map[string]bool{
+  "AccessDenied": true,
+  "AccessDeniedException": true,
+  "UnauthorizedOperation": true,
+}
+
+// Translate an SDK error into one of the typed errors above, or return it untouched if it isn't one we recognize
+func classifyAWSError(err error) error {
+  awsErr, ok := err.(awserr.Error)
+  if !ok {
+    return err
+  }
+
+  if throttlingCodes[awsErr.Code()] {
+    return fmt.Errorf("%w: %s", ErrThrottled, awsErr.Message())
+  }
+  if credentialCodes[awsErr.Code()] {
+    return fmt.Errorf("%w: %s", ErrBadCredentials, awsErr.Message())
+  }
+  if accessDeniedCodes[awsErr.Code()] {
+    return fmt.Errorf("%w: %s", ErrAccessDenied, awsErr.Message())
+  }
+
+  return err
+}
+
+// Whether CloudWatch rejected the request for asking for more datapoints than it returns at once
+func isTooManyDatapoints(err error) bool {
+  awsErr, ok := err.(awserr.Error)
+  return ok && awsErr.Code() == "InvalidParameterCombination" && strings.Contains(awsErr.Message(), "datapoints")
+}
+
+// Splitting can only help with errors caused by the size of the request, not ones that will recur for each sub-request
+func isSplittable(err error) bool {
+  if _, ok := err.(awserr.Error); !ok {
+    return false
+  }
+
+  return !errors.Is(err, ErrThrottled) && !errors.Is(err, ErrBadCredentials) && !errors.Is(err, ErrAccessDenied)
+}
+
+func exitCode(err error) int {
+  switch {
+  case err == nil:
+    return exitOK
+  case errors.Is(err, ErrNoData):
+    return exitNoData
+  case errors.Is(err, ErrThrottled):
+    return exitThrottled
+  case errors.Is(err, ErrBadCredentials):
+    return exitBadCredentials
+  case errors.Is(err, ErrInvalidWindow):
+    return exitInvalidWindow
+  case errors.Is(err, ErrAccessDenied):
+    return exitAccessDenied
+  case errors.Is(err, ErrAlert):
+    return exitAlert
+  case errors.Is(err, ErrRegression):
+    return exitRegression
+  case errors.Is(err, ErrFlatline):
+    return exitFlatline
+  default:
+    return exitFailure
+  }
+}
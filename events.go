@@ -0,0 +1,162 @@
+package main
+
+import (
+  "bufio"
+  "fmt"
+  "math"
+  "os"
+  "sort"
+  "strings"
+  "time"
+)
+
+// Something that happened at a point in time, e.g. a deploy, marked on the graph by -events
+type Event struct {
+  At time.Time
+  Label string
+}
+
+// Characters marking an event's column in the graph and beneath it
+const (
+  eventColumn = '┊'
+  eventMarker = '▲'
+)
+
+// Read `timestamp,label` lines. Blank lines and # comments are skipped.
+func loadEvents(path string) ([]Event, error) {
+  file, err := os.Open(path)
+  if err != nil {
+    return nil, fmt.Errorf("cannot read -events file: %w", err)
+  }
+  defer file.Close()
+
+  events := []Event{}
+  scanner := bufio.NewScanner(file)
+  for lineNumber := 1; scanner.Scan(); lineNumber++ {
+    line := strings.TrimSpace(scanner.Text())
+    if line == "" || strings.HasPrefix(line, "#") {
+      continue
+    }
+
+    parts := strings.SplitN(line, ",", 2)
+    if len(parts) != 2 {
+      return nil, fmt.Errorf("%s:%d: expected timestamp,label, got %q", path, lineNumber, line)
+    }
+    at, err := parseTimestamp(strings.TrimSpace(parts[0]))
+    if err != nil {
+      return nil, fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+    }
+    events = append(events, Event{ At: at, Label: strings.TrimSpace(parts[1]) })
+  }
+  if err := scanner.Err(); err != nil {
+    return nil, err
+  }
+  sort.Slice(events, func (i, j int) bool {
+    return events[i].At.Before(events[j].At)
+  })
+
+  return events, nil
+}
+
+// Draw a column through the graph at each event within the series' window, with a line of markers and a line naming them between the graph and its caption.
+// asciigraph stretches the series' points evenly across width columns just right of the axis, so an event's column follows from the index of its bucket. Indices rather than times keep this right once -compact-gaps has collapsed part of the window.
+func markEvents(graph string, series Series, events []Event, width int) string {
+  if len(series) < 2 || width < 2 {
+    return graph
+  }
+  first, last := series[0].Timestamp, series[len(series) - 1].Timestamp
+  // An event up to a bucket before the first one still lands in the first column, e.g. the deploy -since-deploy starts the window a moment after
+  earliest := first.Add(-series[1].Timestamp.Sub(first))
+
+  events = append([]Event{}, events...)
+  sort.Slice(events, func (i, j int) bool {
+    return events[i].At.Before(events[j].At)
+  })
+  columns := []int{}
+  labels := []string{}
+  for _, event := range events {
+    if event.At.Before(earliest) || event.At.After(last) {
+      continue
+    }
+    index := sort.Search(len(series), func (i int) bool {
+      return !series[i].Timestamp.Before(event.At)
+    })
+    columns = append(columns, int(math.Round(float64(index) / float64(len(series) - 1) * float64(width - 1))))
+    labels = append(labels, fmt.Sprintf("%s %s", formatTime(event.At), event.Label))
+  }
+  if len(columns) == 0 {
+    return graph
+  }
+
+  // Every line but the caption is a row of the graph, with the axis at the same column in each
+  lines := strings.Split(graph, "\n")
+  axis := -1
+  for _, line := range lines[:len(lines) - 1] {
+    if axis = visibleIndex(line, "┤┼"); axis >= 0 {
+      break
+    }
+  }
+  if axis < 0 {
+    return graph
+  }
+
+  markers := []rune(strings.Repeat(" ", axis + 1 + width))
+  for _, column := range columns {
+    for i := range lines[:len(lines) - 1] {
+      lines[i] = replaceVisible(lines[i], axis + 1 + column, eventColumn)
+    }
+    markers[axis + 1 + column] = eventMarker
+  }
+
+  rows, caption := lines[:len(lines) - 1], lines[len(lines) - 1]
+  rows = append(rows, strings.TrimRight(string(markers), " "), strings.Repeat(" ", axis + 1) + string(eventMarker) + " " + strings.Join(labels, ", "), caption)
+  return strings.Join(rows, "\n")
+}
+
+// The column of the first of chars in the line, skipping color escapes, or -1
+func visibleIndex(line string, chars string) int {
+  column := 0
+  escaped := false
+  for _, r := range line {
+    switch {
+    case escaped:
+      escaped = r != 'm'
+      continue
+    case r == '\x1b':
+      escaped = true
+      continue
+    case strings.ContainsRune(chars, r):
+      return column
+    }
+    column++
+  }
+
+  return -1
+}
+
+// Put r at the given column of the line, skipping color escapes, but only where it would overwrite a space so the plotted line stays intact
+func replaceVisible(line string, column int, r rune) string {
+  runes := []rune(line)
+  visible := 0
+  escaped := false
+  for i, current := range runes {
+    switch {
+    case escaped:
+      escaped = current != 'm'
+      continue
+    case current == '\x1b':
+      escaped = true
+      continue
+    }
+    if visible == column {
+      if current == ' ' {
+        runes[i] = r
+      }
+      return string(runes)
+    }
+    visible++
+  }
+
+  // asciigraph trims trailing spaces, so the column may be past the end of the line
+  return string(runes) + strings.Repeat(" ", column - visible) + string(r)
+}
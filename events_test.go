@@ -0,0 +1,26 @@
+package main
+
+import (
+  "strings"
+  "testing"
+  "time"
+)
+
+func TestMarkEventsDrawsDeploy(t *testing.T) {
+  deployedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+  // -since-deploy's window starts a moment after the deploy, once the lookback is measured from a later now
+  start := deployedAt.Add(300 * time.Millisecond)
+  series := Series{}
+  for i := 0; i < 10; i++ {
+    series = append(series, Point{ Timestamp: start.Add(time.Duration(i) * time.Minute), Value: float64(i % 4) })
+  }
+  graph := strings.Join([]string{ " 3.00 ┤   ╭╮", " 0.00 ┼───╯╰──", "caption" }, "\n")
+
+  marked := markEvents(graph, series, []Event{ deployEvent(deployedAt) }, 10)
+  if !strings.ContainsRune(marked, eventMarker) || !strings.Contains(marked, "deploy") {
+    t.Errorf("deploy at the window's start isn't marked:\n%s", marked)
+  }
+  if lines := strings.Split(marked, "\n"); lines[len(lines) - 1] != "caption" {
+    t.Errorf("caption moved off the last line:\n%s", marked)
+  }
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+  "fmt"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// Repeatable string flag, e.g. `-metric a -metric b`
+type stringList []string
+
+func (list *stringList) String() string {
+  return strings.Join(*list, ",")
+}
+
+func (list *stringList) Set(value string) error {
+  *list = append(*list, value)
+  return nil
+}
+
+// Float flag that records whether it was given at all, for flags without a sensible default
+type optionalFloat struct {
+  value float64
+  set bool
+}
+
+func (f *optionalFloat) String() string {
+  if !f.set {
+    return ""
+  }
+
+  return strconv.FormatFloat(f.value, 'f', -1, 64)
+}
+
+func (f *optionalFloat) Set(raw string) error {
+  value, err := strconv.ParseFloat(raw, 64)
+  if err != nil {
+    return err
+  }
+  f.value, f.set = value, true
+
+  return nil
+}
+
+// -align, a bool flag that also takes cron:EXPR to snap windows to a schedule's ticks rather than to -period
+type alignment struct {
+  enabled bool
+  cron *cronSchedule
+  raw string
+}
+
+func (align *alignment) String() string {
+  if align.raw == "" {
+    return "false"
+  }
+
+  return align.raw
+}
+
+func (align *alignment) Set(raw string) error {
+  if strings.HasPrefix(raw, "cron:") {
+    schedule, err := parseCron(strings.TrimPrefix(raw, "cron:"))
+    if err != nil {
+      return err
+    }
+    align.enabled, align.cron, align.raw = true, schedule, raw
+    return nil
+  }
+
+  enabled, err := strconv.ParseBool(raw)
+  if err != nil {
+    return fmt.Errorf("expected true, false, or cron:EXPR")
+  }
+  align.enabled, align.cron, align.raw = enabled, nil, raw
+
+  return nil
+}
+
+// So a bare -align still works
+func (align *alignment) IsBoolFlag() bool {
+  return true
+}
+
+// Snap t back to the last cron tick, or with plain -align to the start of its period
+func (align alignment) snap(t time.Time, period time.Duration) time.Time {
+  switch {
+  case align.cron != nil:
+    tick, _ := align.cron.previous(t)
+    return tick
+  case align.enabled:
+    return t.Truncate(period)
+  }
+
+  return t
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+  "fmt"
+  "math"
+  "strconv"
+  "time"
+)
+
+// The trailing stretch of a series where every datapoint CloudWatch returned had the same value
+type flatline struct {
+  value float64
+  // Start of the first and end of the last bucket of the stretch
+  start, end time.Time
+  // Gap-filled buckets inside the stretch, which say nothing about whether the value changed
+  filled int
+}
+
+// Check the current window of each metric for having gone flat for at least -detect-flatline, e.g. a gauge stuck because its process hung,
+// printing where each flatline began and, with -fail-on-flatline, failing with ErrFlatline if any has.
+func (client Client) checkFlatline(options Options) error {
+  start, end := options.window(time.Now())
+  panels, err := client.fetchPanels(options, start, end)
+  if err != nil {
+    return err
+  }
+
+  flat := 0
+  for _, panel := range panels {
+    period := time.Duration(options.period) * time.Second
+    if panel.Query != nil {
+      period = time.Duration(panel.Query.Period) * time.Second
+    }
+    stretch, ok := trailingFlatline(panel.Series, period)
+    if !ok {
+      fmt.Printf("%s %s: no datapoints in the last %s, only gap-filled buckets, so it can't be told apart from a flatline\n", formatTime(end), panel.Label, -options.lookback)
+      continue
+    }
+
+    // Buckets filled after the last datapoint mean the metric stopped publishing, which is a different problem from it publishing the same value
+    if silent := end.Sub(stretch.end); silent >= options.detectFlatline {
+      fmt.Printf("%s %s: no datapoints since %s (%s), the rest of the window is gap-filled rather than flat\n", formatTime(end), panel.Label, formatTime(stretch.end), silent.Round(time.Second))
+      continue
+    }
+
+    duration := stretch.end.Sub(stretch.start)
+    verdict := "ok"
+    if duration >= options.detectFlatline {
+      verdict = "FLATLINE"
+      flat++
+    }
+    ignored := ""
+    if stretch.filled > 0 {
+      ignored = fmt.Sprintf(", ignoring %d gap-filled bucket(s)", stretch.filled)
+    }
+    fmt.Printf("%s %s: flat at %s since %s (%s%s, threshold %s) %s\n", formatTime(end), panel.Label, strconv.FormatFloat(stretch.value, 'f', -1, 64), formatTime(stretch.start), duration, ignored, options.detectFlatline, verdict)
+  }
+  if flat > 0 && options.failOnFlatline {
+    return fmt.Errorf("%w: %d of %d metric(s) unchanged for at least %s", ErrFlatline, flat, len(panels), options.detectFlatline)
+  }
+
+  return nil
+}
+
+// Walk back from the last datapoint for as long as the value stays the same. Gap-filled and suppressed buckets are skipped rather than compared,
+// since a zero filled into a gap isn't the metric reporting zero. ok is false if the series has no datapoints at all.
+func trailingFlatline(series Series, period time.Duration) (flatline, bool) {
+  last, ok := series.Last()
+  if !ok || math.IsNaN(last.Value) {
+    return flatline{}, false
+  }
+
+  stretch := flatline{ value: last.Value, start: last.Timestamp, end: last.Timestamp.Add(period) }
+  filled := 0
+  for i := len(series) - 1; i >= 0; i-- {
+    point := series[i]
+    if point.Timestamp.After(last.Timestamp) {
+      continue
+    }
+    if point.Filled {
+      filled++
+      continue
+    }
+    if point.Suppressed || math.IsNaN(point.Value) {
+      continue
+    }
+    if point.Value != stretch.value {
+      break
+    }
+    stretch.start = point.Timestamp
+    // Only count the gaps between datapoints of the stretch, not the one before it
+    stretch.filled = filled
+  }
+
+  return stretch, true
+}
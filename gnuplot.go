@@ -0,0 +1,83 @@
+package main
+
+import (
+  "fmt"
+  "math"
+  "os"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// Write each panel, transformed as it would be drawn, as gnuplot data and a script plotting it, for a publication-quality render with e.g.
+// `gnuplot -e "set terminal pngcairo; set output 'plot.png'" plot.gp`. Without -gnuplot-file the script goes to stdout with its data inline.
+func (client Client) writeGnuplot(options Options) error {
+  end := time.Now()
+  panels, err := client.fetchPanels(options, end.Add(options.lookback), end)
+  if err != nil {
+    return err
+  }
+  panels = transformPanels(options, panels, end)
+
+  data := gnuplotData(panels)
+  if options.gnuplotFile == "" {
+    fmt.Print("$data << EOD\n" + data + "EOD\n" + gnuplotScript(options, panels, "$data"))
+    return nil
+  }
+
+  dataPath, scriptPath := options.gnuplotFile + ".dat", options.gnuplotFile + ".gp"
+  if err := os.WriteFile(dataPath, []byte(data), 0644); err != nil {
+    return fmt.Errorf("cannot write -gnuplot-file data: %w", err)
+  }
+  if err := os.WriteFile(scriptPath, []byte(gnuplotScript(options, panels, strconv.Quote(dataPath))), 0644); err != nil {
+    return fmt.Errorf("cannot write -gnuplot-file script: %w", err)
+  }
+  infof("Wrote %s and %s", dataPath, scriptPath)
+
+  return nil
+}
+
+// `timestamp value` lines, one block per series separated by two blank lines so the script can pick each out with `index`. Breaks are NaN, which gnuplot leaves undrawn.
+func gnuplotData(panels []Panel) string {
+  var data strings.Builder
+  for _, panel := range panels {
+    for _, series := range panel.allSeries() {
+      fmt.Fprintf(&data, "# %s\n", series.Label)
+      for _, point := range series.Series {
+        value := "NaN"
+        if !math.IsNaN(point.Value) {
+          value = strconv.FormatFloat(point.Value, 'f', -1, 64)
+        }
+        fmt.Fprintf(&data, "%d %s\n", point.Timestamp.Unix(), value)
+      }
+      data.WriteString("\n\n")
+    }
+  }
+
+  return data.String()
+}
+
+// A minimal script plotting the data at source, one plot per panel titled with its metric
+func gnuplotScript(options Options, panels []Panel, source string) string {
+  var script strings.Builder
+  script.WriteString("set xdata time\nset timefmt \"%s\"\nset format x \"%H:%M\"\nset key below\n")
+  if len(panels) > 1 {
+    fmt.Fprintf(&script, "set multiplot layout %d,1\n", len(panels))
+  }
+
+  index := 0
+  for _, panel := range panels {
+    fmt.Fprintf(&script, "set title %s\n", strconv.Quote(panel.scope(options.namespace)))
+    plots := []string{}
+    for _, series := range panel.allSeries() {
+      plots = append(plots, fmt.Sprintf("%s index %d using 1:2 with lines title %s", source, index, strconv.Quote(series.Label)))
+      index++
+    }
+    script.WriteString("plot " + strings.Join(plots, ", \\\n  ") + "\n")
+  }
+  if len(panels) > 1 {
+    script.WriteString("unset multiplot\n")
+  }
+
+  return script.String()
+}
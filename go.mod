@@ -3,13 +3,13 @@ module github.com/jbaiad/cw-top
 go 1.17
 
 require (
-	github.com/aws/aws-sdk-go v1.40.45
-	github.com/guptarohit/asciigraph v0.5.2
-	golang.org/x/crypto v0.0.0-20210915214749-c084706c2272
+	github.com/aws/aws-sdk-go v1.44.150
+	github.com/guptarohit/asciigraph v0.5.6 // at least 0.5.3, which plots a series starting with a NaN break, as -max-gap leaves them
+	golang.org/x/crypto v0.0.0-20210921155107-089bfa567519
 )
 
 require (
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
-	golang.org/x/sys v0.0.0-20210615035016-665e8c7367d1 // indirect
-	golang.org/x/term v0.0.0-20201126162022-7de9c90e9dd1 // indirect
+	golang.org/x/sys v0.1.0 // indirect
+	golang.org/x/term v0.1.0 // indirect
 )
@@ -0,0 +1,233 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "sort"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// The parts of a Grafana dashboard export that say what a CloudWatch panel graphs
+type grafanaDashboard struct {
+  Panels []grafanaPanel `json:"panels"`
+}
+
+type grafanaPanel struct {
+  Title string `json:"title"`
+  Type string `json:"type"`
+  Datasource json.RawMessage `json:"datasource"`
+  Targets []grafanaTarget `json:"targets"`
+  // Panels folded into a collapsed row
+  Panels []grafanaPanel `json:"panels"`
+}
+
+// A query of the CloudWatch datasource
+type grafanaTarget struct {
+  RefID string `json:"refId"`
+  Datasource json.RawMessage `json:"datasource"`
+  Hide bool `json:"hide"`
+  // Metrics, or Logs for Logs Insights
+  QueryMode string `json:"queryMode"`
+  // 0 for a metric search, 1 for a Metrics Insights query
+  MetricQueryType int `json:"metricQueryType"`
+  // Metric math, or a search expression
+  Expression string `json:"expression"`
+  Namespace string `json:"namespace"`
+  MetricName string `json:"metricName"`
+  // Each value is a string, or a list of them when several are selected
+  Dimensions map[string]json.RawMessage `json:"dimensions"`
+  Statistic string `json:"statistic"`
+  // Older dashboards list statistics instead
+  Statistics []string `json:"statistics"`
+  // Seconds or a duration like 5m, empty or "auto" to let Grafana pick
+  Period string `json:"period"`
+  Label string `json:"label"`
+  Alias string `json:"alias"`
+}
+
+// Whether the JSON is a Grafana dashboard rather than a -dashboard file: Grafana panels have targets, and API exports wrap the dashboard in a "dashboard" key
+func isGrafanaDashboard(raw []byte) bool {
+  probe := struct {
+    Dashboard json.RawMessage `json:"dashboard"`
+    Panels []struct {
+      Targets json.RawMessage `json:"targets"`
+      Panels json.RawMessage `json:"panels"`
+    } `json:"panels"`
+  }{}
+  if json.Unmarshal(raw, &probe) != nil {
+    return false
+  }
+  if len(probe.Dashboard) > 0 {
+    return true
+  }
+  for _, panel := range probe.Panels {
+    if len(panel.Targets) > 0 || len(panel.Panels) > 0 {
+      return true
+    }
+  }
+
+  return false
+}
+
+// Convert each Grafana panel's CloudWatch metric queries into a dashboard panel with a series per query. Rows are flattened and text panels skipped,
+// but anything that can't be fetched as a plain metric, e.g. another datasource, metric math, or a template variable, is an error rather than silently dropped.
+func importGrafanaDashboard(raw []byte) (dashboardFile, error) {
+  wrapped := struct {
+    Dashboard *grafanaDashboard `json:"dashboard"`
+  }{}
+  if err := json.Unmarshal(raw, &wrapped); err != nil {
+    return dashboardFile{}, err
+  }
+  dashboard := grafanaDashboard{}
+  if wrapped.Dashboard != nil {
+    dashboard = *wrapped.Dashboard
+  } else if err := json.Unmarshal(raw, &dashboard); err != nil {
+    return dashboardFile{}, err
+  }
+
+  file := dashboardFile{}
+  for _, panel := range flattenGrafanaPanels(dashboard.Panels) {
+    if len(panel.Targets) == 0 {
+      continue
+    }
+    imported := dashboardFilePanel{ Title: panel.Title }
+    for _, target := range panel.Targets {
+      if target.Hide {
+        continue
+      }
+      datasource := target.Datasource
+      if len(datasource) == 0 || grafanaDatasourceType(datasource) == "datasource" {
+        datasource = panel.Datasource
+      }
+      // "datasource" is Grafana's own pseudo-datasource, e.g. for mixed panels, which leaves nothing to check
+      if kind := grafanaDatasourceType(datasource); kind != "" && kind != "cloudwatch" && kind != "datasource" {
+        return file, fmt.Errorf("Grafana panel %q uses the %s datasource, only CloudWatch panels can be imported", panel.Title, kind)
+      }
+      series, err := importGrafanaTarget(target)
+      if err != nil {
+        return file, fmt.Errorf("Grafana panel %q query %s: %w", panel.Title, target.RefID, err)
+      }
+      imported.Series = append(imported.Series, series)
+    }
+    if len(imported.Series) > 0 {
+      file.Panels = append(file.Panels, imported)
+    }
+  }
+
+  return file, nil
+}
+
+// Panels in rows, collapsed ones included, in dashboard order
+func flattenGrafanaPanels(panels []grafanaPanel) []grafanaPanel {
+  flattened := []grafanaPanel{}
+  for _, panel := range panels {
+    if panel.Type == "row" {
+      flattened = append(flattened, flattenGrafanaPanels(panel.Panels)...)
+      continue
+    }
+    flattened = append(flattened, panel)
+  }
+
+  return flattened
+}
+
+// The datasource's plugin type, e.g. cloudwatch. Older dashboards name the datasource instead, which says nothing about its type, so those are assumed to be CloudWatch and left to the target checks.
+func grafanaDatasourceType(raw json.RawMessage) string {
+  datasource := struct {
+    Type string `json:"type"`
+  }{}
+  if json.Unmarshal(raw, &datasource) != nil {
+    return ""
+  }
+
+  return datasource.Type
+}
+
+func importGrafanaTarget(target grafanaTarget) (dashboardFileSeries, error) {
+  switch {
+  case target.QueryMode == "Logs":
+    return dashboardFileSeries{}, fmt.Errorf("Logs Insights queries can't be imported, use -log-group instead")
+  case target.MetricQueryType == 1:
+    return dashboardFileSeries{}, fmt.Errorf("Metrics Insights queries can't be imported")
+  case target.Expression != "":
+    return dashboardFileSeries{}, fmt.Errorf("metric math and search expressions can't be imported, use -expression instead")
+  case target.MetricName == "" || target.Namespace == "":
+    return dashboardFileSeries{}, fmt.Errorf("no namespace and metric name")
+  }
+  if err := checkGrafanaVariable("metric", target.MetricName); err != nil {
+    return dashboardFileSeries{}, err
+  }
+  if err := checkGrafanaVariable("namespace", target.Namespace); err != nil {
+    return dashboardFileSeries{}, err
+  }
+
+  series := dashboardFileSeries{ Metric: target.MetricName, Namespace: target.Namespace, Stat: target.Statistic, Label: target.Label }
+  if series.Stat == "" && len(target.Statistics) > 0 {
+    series.Stat = target.Statistics[0]
+  }
+  if series.Label == "" {
+    series.Label = target.Alias
+  }
+  if strings.Contains(series.Label, "$") || strings.Contains(series.Label, "{{") {
+    // Dynamic labels name each series from its metadata, which a single query doesn't need
+    series.Label = ""
+  }
+
+  if target.Period != "" && target.Period != "auto" {
+    seconds, err := strconv.ParseInt(target.Period, 10, 64)
+    if err != nil {
+      period, durationErr := time.ParseDuration(target.Period)
+      if durationErr != nil {
+        return series, fmt.Errorf("invalid period %q", target.Period)
+      }
+      seconds = int64(period.Seconds())
+    }
+    series.Period = seconds
+  }
+
+  names := []string{}
+  for name := range target.Dimensions {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+  for _, name := range names {
+    value, err := grafanaDimensionValue(target.Dimensions[name])
+    if err != nil {
+      return series, fmt.Errorf("dimension %s: %w", name, err)
+    }
+    if err := checkGrafanaVariable("dimension " + name, value); err != nil {
+      return series, err
+    }
+    series.Dimensions = append(series.Dimensions, name + "=" + value)
+  }
+
+  return series, nil
+}
+
+// GetMetricStatistics takes one value per dimension, so a list only works if it holds exactly one
+func grafanaDimensionValue(raw json.RawMessage) (string, error) {
+  var value string
+  if json.Unmarshal(raw, &value) == nil {
+    return value, nil
+  }
+  values := []string{}
+  if err := json.Unmarshal(raw, &values); err != nil {
+    return "", fmt.Errorf("expected a string or a list of strings")
+  }
+  if len(values) != 1 {
+    return "", fmt.Errorf("%d values selected, but only one can be graphed per series", len(values))
+  }
+
+  return values[0], nil
+}
+
+// Template variables and wildcards are resolved by Grafana, so there's nothing to fetch until they're replaced with real values
+func checkGrafanaVariable(field string, value string) error {
+  if strings.Contains(value, "$") || value == "*" {
+    return fmt.Errorf("%s %q is a template variable or wildcard; replace it with a value in the export", field, value)
+  }
+
+  return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+  "fmt"
+  "os"
+  "strconv"
+  "strings"
+  "time"
+
+  "github.com/guptarohit/asciigraph"
+  "golang.org/x/crypto/ssh/terminal"
+)
+
+// Narrowest a -grid cell gets, gap included, so the latest value still leaves room for a readable sparkline
+const minGridCellWidth = 32
+
+// Width of the latest value at the start of each cell's sparkline line
+const gridValueWidth = 12
+
+// Draw every series matching the queries, e.g. from a glob or -group-by, as a grid of labelled sparklines filling the terminal.
+// Small multiples give a glance across a fleet that one chart with dozens of lines can't.
+func (client Client) renderGrid(options Options) error {
+  period := time.Duration(options.period) * time.Second
+  resized := make(chan os.Signal, 1)
+  notifyOnResize(resized)
+
+  var panels []Panel
+  var end time.Time
+  for {
+    if panels == nil || !options.tail || time.Since(end) >= tailInterval(period) {
+      end = time.Now()
+      fetched, err := client.fetchPanels(options, end.Add(options.lookback), end)
+      if err != nil {
+        return err
+      }
+      panels = fetched
+    }
+
+    drawn, namespace := panels, options.namespace
+    if redact {
+      drawn, namespace = redactPanels(panels, namespace)
+    }
+    if options.tail {
+      asciigraph.Clear()
+    }
+    printGrid(drawn, namespace, options.lookback, end)
+    if !options.tail {
+      return nil
+    }
+
+    // A resize redraws the grid from the panels already fetched, which are only refetched once the interval is up
+    select {
+    case <-resized:
+    case <-time.After(tailInterval(period) - time.Since(end)):
+    }
+  }
+}
+
+func printGrid(panels []Panel, namespace string, lookback time.Duration, end time.Time) {
+  // When stdout isn't a terminal there's nothing to fit, so every cell is printed at the narrowest width
+  width, height := minGridCellWidth, 0
+  if terminalWidth, terminalHeight, err := terminal.GetSize(int(os.Stdin.Fd())); err == nil {
+    width, height = terminalWidth, terminalHeight
+  }
+  columns := width / minGridCellWidth
+  if columns < 1 {
+    columns = 1
+  }
+  cellWidth := width / columns - 2
+
+  // Each row of cells takes a label line, a sparkline line, and a blank line, under a line of header
+  shown := panels
+  if rows := (height - 1) / 3; height > 0 && rows > 0 && len(shown) > rows * columns {
+    shown = shown[:rows * columns]
+  }
+
+  header := fmt.Sprintf("[%s] %d series with lookback=%s (last updated at %s)", namespace, len(panels), lookback, formatTime(end))
+  if len(shown) < len(panels) {
+    header = fmt.Sprintf("[%s] %d of %d series with lookback=%s, enlarge the terminal for the rest (last updated at %s)", namespace, len(shown), len(panels), lookback, formatTime(end))
+  }
+  fmt.Println(header)
+
+  for row := 0; row < len(shown); row += columns {
+    cells := shown[row:]
+    if len(cells) > columns {
+      cells = cells[:columns]
+    }
+
+    labels, sparklines := []string{}, []string{}
+    for _, panel := range cells {
+      labels = append(labels, fmt.Sprintf("%-*s", cellWidth, truncateLabel(panel.Label, cellWidth)))
+      value := "no data"
+      if latest, ok := panel.Series.Last(); ok {
+        value = strconv.FormatFloat(latest.Value, 'g', 6, 64)
+      }
+      sparklineWidth := cellWidth - gridValueWidth - 1
+      sparklines = append(sparklines, fmt.Sprintf("%*s %-*s", gridValueWidth, value, sparklineWidth, sparkline(panel.Series.Values(), sparklineWidth)))
+    }
+    fmt.Println(strings.TrimRight(strings.Join(labels, "  "), " "))
+    fmt.Println(strings.TrimRight(strings.Join(sparklines, "  "), " "))
+    fmt.Println()
+  }
+}
+
+// Cut the label down to width characters from the front, since the dimension values that tell cells apart come last
+func truncateLabel(label string, width int) string {
+  runes := []rune(label)
+  if len(runes) <= width {
+    return label
+  }
+
+  return "…" + string(runes[len(runes) - width + 1:])
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+  "fmt"
+  "sync"
+
+  "github.com/aws/aws-sdk-go/service/sts"
+)
+
+// Name the missing permission and, if STS will tell us, who is missing it
+func (client Client) explainAccessDenied(err error, permission string) error {
+  identity := "the current identity"
+  if output, stsErr := sts.New(client.session).GetCallerIdentity(&sts.GetCallerIdentityInput{}); stsErr == nil && output.Arn != nil {
+    identity = *output.Arn
+  }
+
+  return fmt.Errorf("%w; %s needs the %s IAM permission", err, identity, permission)
+}
+
+// The caller's account ID, looked up with STS once and shared by copies of the client
+type callerAccount struct {
+  once sync.Once
+  id string
+  err error
+}
+
+// The AWS account the client's credentials belong to
+func (client Client) accountID() (string, error) {
+  if client.account == nil {
+    return "", fmt.Errorf("account lookup unavailable")
+  }
+  client.account.once.Do(func () {
+    output, err := sts.New(client.session).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+    switch {
+    case err != nil:
+      client.account.err = fmt.Errorf("cannot look up the account: %w", err)
+    case output.Account == nil:
+      client.account.err = fmt.Errorf("STS returned no account")
+    default:
+      client.account.id = *output.Account
+    }
+  })
+
+  return client.account.id, client.account.err
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+  "errors"
+  "fmt"
+  "net/http"
+  "strings"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/aws/ec2metadata"
+  "github.com/aws/aws-sdk-go/aws/session"
+  "github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// An instance tag holding the namespace to default -namespace to
+const namespaceTag = "cw-top:namespace"
+
+// Not an error as such: there's no instance to read tags from, so -from-instance-tags is skipped
+var errNotOnEC2 = errors.New("no EC2 instance metadata available")
+
+// Scope the run to this instance's metrics: each of the comma-separated tags becomes a -dimension of the same name, or of the name after an =,
+// e.g. aws:autoscaling:groupName=AutoScalingGroupName. InstanceId stands for the instance's own ID. A cw-top:namespace tag defaults -namespace.
+// Dimensions and a namespace given on the command line win, and off EC2 this only warns.
+func applyInstanceTags(options Options, raw string, explicit map[string]bool) (Options, error) {
+  // Tag keys can contain colons, like aws:autoscaling:groupName, but dimension names can't hold an =
+  mappings := [][2]string{}
+  for _, mapping := range strings.Split(raw, ",") {
+    tag, dimension := mapping, mapping
+    if i := strings.LastIndex(mapping, "="); i >= 0 {
+      tag, dimension = mapping[:i], mapping[i + 1:]
+    }
+    if tag == "" || dimension == "" {
+      return options, fmt.Errorf("invalid -from-instance-tags entry %q, expected TAG or TAG=DIMENSION", mapping)
+    }
+    mappings = append(mappings, [2]string{ tag, dimension })
+  }
+
+  instanceID, tags, err := instanceTags(options)
+  if errors.Is(err, errNotOnEC2) {
+    warnf("Warning: -from-instance-tags ignored, %s", err.Error())
+    return options, nil
+  }
+  if err != nil {
+    return options, fmt.Errorf("cannot read -from-instance-tags: %w", err)
+  }
+
+  explicitDimensions := map[string]bool{}
+  for _, dimension := range options.dimensions {
+    explicitDimensions[strings.SplitN(dimension, "=", 2)[0]] = true
+  }
+  for _, mapping := range mappings {
+    tag, dimension := mapping[0], mapping[1]
+    if explicitDimensions[dimension] {
+      continue
+    }
+
+    value, ok := tags[tag]
+    if tag == "InstanceId" {
+      value, ok = instanceID, true
+    }
+    if !ok {
+      warnf("Warning: instance %s has no %s tag, so it isn't used as a dimension", instanceID, tag)
+      continue
+    }
+    options.dimensions = append(options.dimensions, dimension + "=" + value)
+  }
+
+  if namespace, ok := tags[namespaceTag]; ok && !explicit["namespace"] {
+    options.namespace = namespace
+  }
+  if options.info {
+    infof("Scoped to instance %s: -namespace %s, -dimension %s", instanceID, options.namespace, options.dimensions.String())
+  }
+
+  return options, nil
+}
+
+// The instance's ID and tags, from instance metadata if tags are exposed there, otherwise from DescribeTags in the instance's own region with the run's credentials
+func instanceTags(options Options) (string, map[string]string, error) {
+  sess, err := session.NewSession(&aws.Config{ MaxRetries: aws.Int(0) })
+  if err != nil {
+    return "", nil, err
+  }
+  metadata := ec2metadata.New(sess, &aws.Config{ HTTPClient: &http.Client{ Timeout: metadataTimeout } })
+  if !metadata.Available() {
+    return "", nil, errNotOnEC2
+  }
+  identity, err := metadata.GetInstanceIdentityDocument()
+  if err != nil {
+    return "", nil, err
+  }
+
+  tags := map[string]string{}
+  if keys, err := metadata.GetMetadata("tags/instance"); err == nil {
+    for _, key := range strings.Fields(keys) {
+      value, err := metadata.GetMetadata("tags/instance/" + key)
+      if err != nil {
+        return identity.InstanceID, nil, err
+      }
+      tags[key] = value
+    }
+    return identity.InstanceID, tags, nil
+  }
+
+  // Tags aren't in metadata unless the instance was launched with them enabled
+  apiSession, err := newSession(options, identity.Region)
+  if err != nil {
+    return identity.InstanceID, nil, err
+  }
+  input := &ec2.DescribeTagsInput{ Filters: []*ec2.Filter{ { Name: aws.String("resource-id"), Values: []*string{ &identity.InstanceID } } } }
+  err = ec2.New(apiSession).DescribeTagsPages(input, func (page *ec2.DescribeTagsOutput, lastPage bool) bool {
+    for _, tag := range page.Tags {
+      tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+    }
+    return true
+  })
+  if err != nil {
+    err = classifyAWSError(err)
+    if errors.Is(err, ErrAccessDenied) {
+      err = fmt.Errorf("%w; allow ec2:DescribeTags, or enable tags in instance metadata", err)
+    }
+    return identity.InstanceID, nil, err
+  }
+
+  return identity.InstanceID, tags, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+  "bufio"
+  "flag"
+  "fmt"
+  "os"
+  "strings"
+)
+
+// Per-directory defaults, meant to be git-ignored so each developer can keep their own
+const localDefaultsFile = ".cw-top.local"
+
+// The flags the local defaults file may set: what to query and how to draw it. Anything that runs a command, writes a file, listens, or changes
+// credentials or where requests go is left out, since the file comes with whatever directory cw-top is run in, including a cloned repo.
+var localDefaultFlags = map[string]bool{
+  "namespace": true, "metric": true, "dimension": true, "region": true,
+  "stat": true, "derive": true, "min-samples": true, "period": true, "auto-period": true, "lookback": true, "points": true, "align": true, "drop-last": true,
+  "fill": true, "max-gap": true, "trim-zeros": true, "compact-gaps": true,
+  "stack": true, "stacked": true, "group-by": true, "group-by-limit": true, "top-by": true, "compare-mode": true, "compare-stat": true, "regression-stat": true,
+  "normalize": true, "band": true, "dual-axis": true, "highlight-zscore": true, "zscore-window": true,
+  "display-period": true, "display-aggregate": true, "resample-to": true, "resample-aggregate": true,
+  "ymin": true, "ymax": true, "threshold": true, "threshold-below": true, "threshold-include-filled": true,
+  "time-format": true, "caption-template": true, "quiet": true, "redact": true, "info": true,
+}
+
+// The flags given on the command line. Taken before local defaults are applied, since flag.Set marks a flag as given just as the command line does,
+// so parse checks this rather than flag.Visit when a default mustn't count as an explicit choice.
+func explicitFlags() map[string]bool {
+  explicit := map[string]bool{}
+  flag.Visit(func (f *flag.Flag) {
+    explicit[f.Name] = true
+  })
+
+  return explicit
+}
+
+// Apply `flag=value` lines from the local defaults file to every flag not given explicitly on the command line. A missing file is fine, but a flag outside localDefaultFlags isn't.
+func applyLocalDefaults(path string, explicit map[string]bool) error {
+  file, err := os.Open(path)
+  if os.IsNotExist(err) {
+    return nil
+  }
+  if err != nil {
+    return err
+  }
+  defer file.Close()
+
+  scanner := bufio.NewScanner(file)
+  for lineNumber := 1; scanner.Scan(); lineNumber++ {
+    line := strings.TrimSpace(scanner.Text())
+    if line == "" || strings.HasPrefix(line, "#") {
+      continue
+    }
+
+    parts := strings.SplitN(line, "=", 2)
+    if len(parts) != 2 {
+      return fmt.Errorf("%s:%d: expected flag=value, got %q", path, lineNumber, line)
+    }
+    name := strings.TrimLeft(strings.TrimSpace(parts[0]), "-")
+    value := strings.TrimSpace(parts[1])
+    if flag.Lookup(name) == nil {
+      return fmt.Errorf("%s:%d: unknown flag %q", path, lineNumber, name)
+    }
+    if !localDefaultFlags[name] {
+      return fmt.Errorf("%s:%d: flag %q can't be set here, only query and display flags can; pass it on the command line", path, lineNumber, name)
+    }
+    if explicit[name] {
+      continue
+    }
+    if err := flag.Set(name, value); err != nil {
+      return fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+    }
+  }
+
+  return scanner.Err()
+}
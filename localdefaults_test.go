@@ -0,0 +1,32 @@
+package main
+
+import (
+  "flag"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+)
+
+func TestLocalDefaultsAllowlist(t *testing.T) {
+  // Every allowed flag has to exist, or the allowlist has drifted from the flags parse registers
+  if flag.Lookup("namespace") == nil {
+    parse()
+  }
+  for name := range localDefaultFlags {
+    if flag.Lookup(name) == nil {
+      t.Errorf("localDefaultFlags allows %q, which isn't a flag", name)
+    }
+  }
+
+  for _, line := range []string{ "on-update=touch pwned", "credentials-command=cat ~/.aws/credentials", "-proxy=http://example.com", "serve-graph=:8080", "secret-key=x" } {
+    path := filepath.Join(t.TempDir(), localDefaultsFile)
+    if err := os.WriteFile(path, []byte("period=300\n" + line + "\n"), 0600); err != nil {
+      t.Fatal(err)
+    }
+    err := applyLocalDefaults(path, map[string]bool{})
+    if err == nil || !strings.HasPrefix(err.Error(), path + ":2: ") {
+      t.Errorf("%q: got %v, want it rejected at %s:2", line, err, path)
+    }
+  }
+}
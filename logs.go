@@ -0,0 +1,118 @@
+package main
+
+import (
+  "fmt"
+  "sort"
+  "strconv"
+  "strings"
+  "time"
+
+  "github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+// Logs Insights formats timestamps like `2021-09-20 12:34:00.000`, in UTC
+const insightsTimestampLayout = "2006-01-02 15:04:05.000"
+
+// How often to check on a running Logs Insights query
+const insightsPollInterval = time.Second
+
+// Render the result of a Logs Insights query as a time series. This is entirely separate from the metrics path: it never touches GetMetricStatistics.
+func (client Client) renderLogsInsights(options Options) error {
+  end := time.Now()
+  panel, err := client.runInsightsQuery(options, end.Add(options.lookback), end)
+  if err != nil {
+    return err
+  }
+
+  if err := render([]Panel{ panel }, options.logGroup, options.lookback, end); err != nil {
+    return err
+  }
+
+  // Insights results are already aggregated over the whole window, so tailing re-runs the query rather than appending to it
+  for options.tail {
+    time.Sleep(time.Duration(1) * time.Minute)
+
+    end = time.Now()
+    panel, err = client.runInsightsQuery(options, end.Add(options.lookback), end)
+    if err != nil {
+      return err
+    }
+    if err := render([]Panel{ panel }, options.logGroup, options.lookback, end); err != nil {
+      return err
+    }
+  }
+
+  return nil
+}
+
+func (client Client) runInsightsQuery(options Options, start time.Time, end time.Time) (Panel, error) {
+  logs := cloudwatchlogs.New(client.session)
+  startSeconds, endSeconds := start.Unix(), end.Unix()
+  started, err := logs.StartQuery(&cloudwatchlogs.StartQueryInput{
+    LogGroupName: &options.logGroup,
+    QueryString: &options.query,
+    StartTime: &startSeconds,
+    EndTime: &endSeconds,
+  })
+  if err != nil {
+    return Panel{}, classifyAWSError(err)
+  }
+
+  for {
+    results, err := logs.GetQueryResults(&cloudwatchlogs.GetQueryResultsInput{ QueryId: started.QueryId })
+    if err != nil {
+      return Panel{}, classifyAWSError(err)
+    }
+
+    switch *results.Status {
+    case cloudwatchlogs.QueryStatusScheduled, cloudwatchlogs.QueryStatusRunning:
+      time.Sleep(insightsPollInterval)
+    case cloudwatchlogs.QueryStatusComplete:
+      return parseInsightsResults(results.Results)
+    default:
+      return Panel{}, fmt.Errorf("Logs Insights query ended with status %s", *results.Status)
+    }
+  }
+}
+
+// Each row must have a timestamp (a `bin(...)` or `@timestamp` field) and a numeric field, e.g. from `stats count(*) by bin(1m)`
+func parseInsightsResults(rows [][]*cloudwatchlogs.ResultField) (Panel, error) {
+  label := ""
+  series := Series{}
+  for _, row := range rows {
+    var point Point
+    hasTimestamp, hasValue := false, false
+    for _, field := range row {
+      if field.Field == nil || field.Value == nil {
+        continue
+      }
+
+      if strings.HasPrefix(*field.Field, "bin(") || *field.Field == "@timestamp" {
+        timestamp, err := time.Parse(insightsTimestampLayout, *field.Value)
+        if err != nil {
+          return Panel{}, fmt.Errorf("unexpected timestamp %q in field %s: %w", *field.Value, *field.Field, err)
+        }
+        point.Timestamp = timestamp
+        hasTimestamp = true
+      } else if value, err := strconv.ParseFloat(*field.Value, 64); err == nil && !hasValue {
+        point.Value = value
+        hasValue = true
+        label = *field.Field
+      }
+    }
+
+    if !hasTimestamp || !hasValue {
+      return Panel{}, fmt.Errorf("query results need a bin(...) or @timestamp field and a numeric field, e.g. `stats count(*) by bin(1m)`")
+    }
+    series = append(series, point)
+  }
+  if len(series) == 0 {
+    return Panel{}, fmt.Errorf("%w from the Logs Insights query", ErrNoData)
+  }
+
+  sort.Slice(series, func (i, j int) bool {
+    return series[i].Timestamp.Before(series[j].Timestamp)
+  })
+
+  return Panel{ Label: label, Series: series }, nil
+}
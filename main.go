@@ -1,16 +1,26 @@
 package main
 
 import (
+  "crypto/tls"
+  "errors"
   "flag"
   "fmt"
   "math"
+  "net/http"
+  "net/url"
   "os"
   "sort"
   "strings"
+  "sync"
+  "sync/atomic"
+  "text/template"
   "time"
 
   "github.com/aws/aws-sdk-go/aws"
-  "github.com/aws/aws-sdk-go/aws/awserr"
+  "github.com/aws/aws-sdk-go/aws/credentials"
+  "github.com/aws/aws-sdk-go/aws/credentials/processcreds"
+  "github.com/aws/aws-sdk-go/aws/endpoints"
+  "github.com/aws/aws-sdk-go/aws/request"
   "github.com/aws/aws-sdk-go/aws/session"
   "github.com/aws/aws-sdk-go/service/cloudwatch"
   "github.com/guptarohit/asciigraph"
@@ -19,116 +29,1385 @@ import (
 
 type Client struct {
   connection *cloudwatch.CloudWatch
+  session *session.Session
+  // Number of sub-requests a request is split into when CloudWatch rejects it
+  parallelism int
+  // ListMetrics results are cached on disk this long, if at all
+  discoveryCacheTTL time.Duration
+  // Ignore (and refresh) cached discovery results
+  noCache bool
+  // Have ListMetrics include metrics from source accounts linked to this monitoring account
+  includeLinkedAccounts bool
+  // Have ListMetrics only return metrics with datapoints in the last 3 hours
+  recentlyActive bool
+  // Set by -namespace-prefix: discovery lists every namespace and keeps those starting with it
+  namespacePrefix string
+  // overflowSplit or overflowCoarsen
+  onOverflow string
+  // Fetch older parts of the window at coarser periods
+  variableResolution bool
+  // Hide Average buckets computed from fewer samples than this
+  minSamples float64
+  // Report extra detail about each fetch
+  info bool
+  // Print every raw GetMetricStatistics response
+  dumpResponse bool
+  // GetMetricStatistics calls sent, counting each split and retry. Shared by copies of the client.
+  requestCount *int64
+  // GetMetricData calls sent, counted the same way
+  dataRequestCount *int64
+  // Total nanoseconds spent on the attempts of both, for -self-metrics
+  fetchLatency *int64
+  // Calls sent by the fetch this copy of the client is doing, for -info, or nil when they aren't being counted
+  fetchCalls *fetchCalls
+  // For -on-overflow coarsen: the period each coarsened request was fetched at, by requestKey, so later polls stay at it. Shared by copies of the client.
+  coarsenedPeriods *sync.Map
+  // The account the credentials belong to, which keys the discovery cache. Shared by copies of the client.
+  account *callerAccount
+  // Paces metric API calls to -rate-limit, or nil for no limit. Shared by copies of the client, including those for other regions.
+  limiter *rateLimiter
+}
+
+// Options holds everything parsed from the command line
+type Options struct {
+  metrics stringList
+  namespace string
+  dimensions stringList
+  stack string
+  groupBy string
+  groupByLimit int
+  dashboard string
+  dashboardPanels []dashboardPanel
+  // Logs Insights mode, used instead of metrics when -log-group is set
+  logGroup string
+  query string
+  // One per metric, with dimensions applied
+  queries []MetricQuery
+  lookback time.Duration
+  autoPeriod bool
+  // From -multi-lookback: the windows drawn one above the other, and the period each is fetched at
+  multiLookback []time.Duration
+  multiLookbackPeriods []int64
+  // Set by -points, which replaces -lookback
+  points int64
+  maxWindow time.Duration
+  // Set by -since-deploy, which replaces -lookback
+  deployedAt time.Time
+  period int64
+  stat string
+  derive string
+  band string
+  normalize string
+  dualAxis bool
+  highlightZScore float64
+  zscoreWindow int
+  minSamples float64
+  api string
+  tail bool
+  tailHistoryLimit int
+  onUpdate string
+  maxGap time.Duration
+  trimZeros bool
+  compactGaps time.Duration
+  fill string
+  diff bool
+  compareMetrics bool
+  // From -compare-stat: the first is drawn as each panel and the second over it
+  compareStats []string
+  compare time.Duration
+  displayPeriod time.Duration
+  displayAggregate string
+  resampleTo time.Duration
+  resampleAggregate string
+  threshold optionalFloat
+  yMin optionalFloat
+  yMax optionalFloat
+  captionTemplate *template.Template
+  events []Event
+  expression string
+  alertAbove optionalFloat
+  alertContinue bool
+  pollInterval time.Duration
+  waitForData time.Duration
+  thresholdBelow bool
+  thresholdIncludeFilled bool
+  dropLast bool
+  align alignment
+  compareMode string
+  compareNamespaces []string
+  compareRegions []string
+  saveBaseline string
+  // Loaded from -baseline, by metric label
+  baseline map[string]Series
+  regressionThreshold optionalFloat
+  regressionStat string
+  detectFlatline time.Duration
+  failOnFlatline bool
+  stacked bool
+  top bool
+  topBy string
+  grid bool
+  output string
+  socket string
+  // With -output gnuplot, write BASE.dat and BASE.gp rather than a self-contained script to stdout
+  gnuplotFile string
+  // Render once to a timestamped text file in this directory instead of the terminal, keeping the newest snapshotKeep if set
+  snapshot string
+  snapshotKeep int
+  // Print a JSON health report instead of rendering, always exiting 0
+  probe bool
+  // Page through history by this much per key press
+  windowStep time.Duration
+  // Copy what would be printed to the clipboard instead
+  clipboard bool
+  fromFile string
+  archive string
+  info bool
+  dumpResponse bool
+  parallelism int
+  onOverflow string
+  rateLimit float64
+  // Namespace to publish this run's own API usage to, if any
+  selfMetrics string
+  // Treat a series with only gap-filled buckets as ErrNoData, which would otherwise only be a warning
+  failOnEmpty bool
+  benchmark int
+  serveGraph string
+  doctor bool
+  describe bool
+  discoveryCacheTTL time.Duration
+  noCache bool
+  includeLinkedAccounts bool
+  recentlyActive bool
+  namespacePrefix string
+  variableResolution bool
+  region string
+  fips bool
+  proxy string
+  insecureSkipVerify bool
+
+  // Prints credentials as JSON in the credential_process format, re-run whenever they expire
+  credentialsCommand string
+
+  // Static credentials, only used when all three are provided
+  accessKey string
+  secretKey string
+  sessionToken string
 }
 
 func main() {
-  metric, namespace, lookback, tail, err := parse()
+  options, err := parse()
+  if err != nil {
+    errorln("Failed to parse args:", err.Error())
+    os.Exit(exitCode(err))
+  }
+  clipboard := startClipboard(options)
+
+  if options.fromFile != "" {
+    err := renderFromFile(options)
+    clipboard.finish()
+    if err != nil {
+      errorln("Failed to render metric:", err.Error())
+      os.Exit(exitCode(err))
+    }
+    return
+  }
+
+  client, err := createClient(options)
+  if err != nil && options.probe {
+    printProbe(failedProbe(options, err))
+    clipboard.finish()
+    return
+  }
   if err != nil {
-    fmt.Println("Failed to parse args:", err.Error())
+    errorln("Failed to create client:", err.Error())
+    os.Exit(exitCode(err))
+  }
+  if options.doctor {
+    err := client.doctor(options)
+    clipboard.finish()
+    if err != nil {
+      errorln(err.Error())
+      os.Exit(exitCode(err))
+    }
     return
   }
 
-  client := createClient()
-  client.renderMetricSampleCounts(metric, namespace, lookback, tail)
+  var reporter *selfMetricsReporter
+  if options.selfMetrics != "" {
+    reporter = newSelfMetricsReporter(client, options.selfMetrics)
+    go reporter.reportPeriodically()
+  }
+
+  options, err = client.resolveQueries(options)
+  if err != nil && options.probe {
+    printProbe(failedProbe(options, err))
+    clipboard.finish()
+    return
+  }
+  if err != nil {
+    errorln("Failed to discover metrics:", err.Error())
+    os.Exit(exitCode(err))
+  }
+  if len(options.compareNamespaces) > 0 {
+    err := client.checkNamespacesHaveMetric(options)
+    if err != nil && options.probe {
+      printProbe(failedProbe(options, err))
+      clipboard.finish()
+      return
+    }
+    if err != nil {
+      errorln("Failed to discover metrics:", err.Error())
+      os.Exit(exitCode(err))
+    }
+  }
+
+  switch {
+  case options.describe:
+    err = client.describe(options)
+  case options.probe:
+    err = client.probe(options)
+  case options.regressionThreshold.set:
+    err = client.checkRegression(options)
+  case options.detectFlatline > 0:
+    err = client.checkFlatline(options)
+  case options.alertAbove.set:
+    err = client.watch(options)
+  case options.archive != "":
+    err = client.renderArchive(options)
+  case options.top:
+    err = client.renderTop(options)
+  case options.grid:
+    err = client.renderGrid(options)
+  case len(options.compareRegions) > 0:
+    err = client.renderRegions(options)
+  case options.logGroup != "":
+    err = client.renderLogsInsights(options)
+  case options.serveGraph != "":
+    err = client.serveGraph(options)
+  case options.snapshot != "":
+    err = client.writeSnapshot(options)
+  case options.benchmark > 0:
+    err = client.benchmark(options)
+  case options.output == "last":
+    err = client.printLastValue(options)
+  case options.output == "jsonl":
+    err = client.streamJSONLines(options)
+  case options.output == "influx":
+    err = client.streamInflux(options)
+  case options.output == "chartjson":
+    err = client.printChartJSON(options)
+  case options.output == "values":
+    err = client.printValues(options)
+  case options.output == "gnuplot":
+    err = client.writeGnuplot(options)
+  case options.output == "openmetrics":
+    err = client.printOpenMetrics(options)
+  case options.socket != "":
+    err = client.streamSocket(options)
+  case len(options.multiLookback) > 0:
+    err = client.renderMultiLookback(options)
+  case options.windowStep > 0:
+    err = client.renderStepped(options)
+  default:
+    err = client.renderMetricSampleCounts(options)
+  }
+  if reporter != nil {
+    reporter.report()
+  }
+  // Before handling the error, since e.g. a -regression-threshold report is still worth pasting
+  clipboard.finish()
+  if err != nil {
+    // The alert itself has already been printed
+    if !errors.Is(err, ErrAlert) {
+      errorln("Failed to render metric:", err.Error())
+    }
+    os.Exit(exitCode(err))
+  }
 }
 
-func parse() (string, string, time.Duration, bool, error) {
-  lookbackPtr := flag.String("lookback", "-12h", "Amount of metric history to fetch")
-  metric := flag.String("metric", "scheduled-charge-due-or-cdq-lte-30|updated", "Name of the metric to visualize")
-  namespace := flag.String("namespace", "PlaidCron", "Namespace in which the metric exists")
-  tail := flag.Bool("tail", false, "Tail metric, polling it every minute (the frequency w/ which metrics are updated)")
+func parse() (Options, error) {
+  options := Options{}
+  lookbackPtr := flag.String("lookback", "-12h", "Amount of metric history to fetch, e.g. 90m, 7d, or 1d12h")
+  flag.Int64Var(&options.points, "points", 0, "Fetch this many datapoints at -period ending now, instead of -lookback")
+  multiLookbackPtr := flag.String("multi-lookback", "", "Draw each of these comma-separated windows ending now in its own panel, e.g. 1h,24h for detail and context at once, each at the finest period that fits unless -period is given")
+  flag.Var(&options.metrics, "metric", "Name of the metric to visualize (repeatable; defaults to scheduled-charge-due-or-cdq-lte-30|updated)")
+  flag.StringVar(&options.namespace, "namespace", "PlaidCron", "Namespace in which the metric exists")
+  fromInstanceTagsPtr := flag.String("from-instance-tags", "", "On EC2, add a -dimension for each of these comma-separated tags of this instance, e.g. App or aws:autoscaling:groupName=AutoScalingGroupName (InstanceId is the instance's ID), and default -namespace to its cw-top:namespace tag")
+  sincePtr := flag.String("since", "", "Start the window at a time given as a phrase, e.g. \"2 hours ago\", yesterday, or monday, instead of -lookback")
+  sinceDeployPtr := flag.String("since-deploy", "", "File holding a deploy time (RFC 3339 or Unix seconds) to start the window at, instead of -lookback")
+  maxWindowPtr := flag.String("max-window", "", "Refuse lookbacks longer than this, e.g. 30d (defaults to how long CloudWatch retains data at -period)")
+  flag.Int64Var(&options.period, "period", 60, "Granularity of each datapoint in seconds (1, 5, 10, 30, or a multiple of 60)")
+  flag.BoolVar(&options.autoPeriod, "auto-period", false, "Pick the finest -period that fits the lookback into CloudWatch's 1440 datapoints per request and is still retained for all of it")
+  flag.StringVar(&options.stat, "stat", cloudwatch.StatisticSampleCount, "Statistic to graph: SampleCount, Sum, Average, Minimum, Maximum, or a percentile like p99")
+  flag.StringVar(&options.derive, "derive", "", "Derive a value from several statistics: average (Sum / SampleCount, for metrics published as StatisticSets; requires -stat SampleCount)")
+  flag.Float64Var(&options.minSamples, "min-samples", 0, "With -stat Average, hide buckets averaged from fewer samples than this, since they're mostly noise")
+  flag.StringVar(&options.band, "band", "", "With -stat Average, draw a band around the mean: stddev (p16 to p84, ±1σ for normal data) or iqr (p25 to p75)")
+  flag.StringVar(&options.normalize, "normalize", "", "Rescale each series before plotting so overlays of different magnitudes can be compared by shape: maxpct (percent of its own max) or zscore")
+  flag.BoolVar(&options.dualAxis, "dual-axis", false, "Scale series drawn over a panel onto its axis, labelling each with its own range, for overlays in different units")
+  flag.Float64Var(&options.highlightZScore, "highlight-zscore", 0, "Mark points more than this many standard deviations from the rolling mean in red and list them under the graph, e.g. 3")
+  flag.IntVar(&options.zscoreWindow, "zscore-window", 30, "How many datapoints before each point -highlight-zscore takes the rolling mean and standard deviation over")
+  flag.Var(&options.dimensions, "dimension", "Dimension filter as Name=Value (repeatable)")
+  flag.StringVar(&options.stack, "stack", "", "CloudFormation stack whose resources in -namespace to graph, one panel each (implies -stacked)")
+  flag.StringVar(&options.groupBy, "group-by", "", "Dimension to graph the -metric by, one panel per value found with ListMetrics (implies -stacked)")
+  flag.IntVar(&options.groupByLimit, "group-by-limit", 10, "Most values of -group-by to graph")
+  flag.StringVar(&options.dashboard, "dashboard", "", "JSON file of panels to draw, each holding series with their own metric, namespace, stat, period, dimensions, label, and color; or a Grafana dashboard export, whose CloudWatch panels are drawn")
+  flag.StringVar(&options.logGroup, "log-group", "", "Graph a Logs Insights -query over this log group instead of a metric")
+  flag.StringVar(&options.query, "query", "", "Logs Insights query producing a timestamp and a number per row, e.g. `stats count(*) by bin(1m)`")
+  flag.BoolVar(&options.tail, "tail", false, "Tail metric, polling each series once per period (at most every 10s) and redrawing whenever any of them updates")
+  flag.IntVar(&options.tailHistoryLimit, "tail-history-limit", 0, "With -tail, keep at most this many buckets per series in memory (defaults to as many as fit in -lookback)")
+  flag.StringVar(&options.onUpdate, "on-update", "", "Power users: with -tail, run this shell command after each poll that brings new data, once per updated series, with its latest value, timestamp, and metric as $1, $2, $3 and CW_TOP_VALUE, CW_TOP_TIMESTAMP, CW_TOP_METRIC")
+  flag.StringVar(&options.fill, "fill", "zero", "How to fill buckets with no datapoint: zero, repeat to carry the last datapoint forward, or none to leave a break (useful when zero is a meaningful value). GetMetricData fetches are filled by CloudWatch's FILL metric math, as in the console, unless -max-gap is set.")
+  flag.DurationVar(&options.maxGap, "max-gap", 0, "Only zero-fill gaps shorter than this, leaving longer ones as breaks in the graph (0 fills every gap)")
+  flag.BoolVar(&options.trimZeros, "trim-zeros", false, "Drop the filled buckets before a metric's first and after its last datapoint, so the graph focuses on when it was published")
+  flag.DurationVar(&options.compactGaps, "compact-gaps", 0, "Collapse runs of filled buckets at least this long, e.g. 30m, into a short marked break so sparse data gets most of the width")
+  flag.BoolVar(&options.diff, "diff", false, "Render the first -metric minus the second -metric (requires exactly two)")
+  flag.BoolVar(&options.compareMetrics, "compare-metrics", false, "Draw the second -metric over the first and print their correlation under the graph (requires exactly two)")
+  compareStatPtr := flag.String("compare-stat", "", "Draw two comma-separated statistics of each -metric on the same axes instead of -stat, e.g. Average,Maximum to see how far peaks stray from the mean")
+  flag.StringVar(&options.output, "output", "graph", "Output format: " + strings.Join(outputFormats, ", "))
+  flag.StringVar(&options.snapshot, "snapshot", "", "Render the graph once to METRIC-YYYYMMDD-HHMM.txt in this directory, stamped in UTC with the window's end, as plain text without colors for diffing, e.g. from a nightly cron with -align and -drop-last")
+  flag.IntVar(&options.snapshotKeep, "snapshot-keep", 0, "With -snapshot, delete all but this many of the newest snapshots of the same metrics (0 keeps them all)")
+  flag.BoolVar(&options.clipboard, "clipboard", false, "Copy the output, graph or otherwise, to the clipboard with pbcopy, wl-copy, xclip, xsel, or clip.exe instead of printing it, falling back to printing if there's none")
+  windowStepPtr := flag.String("window-step", "", "Page through history interactively, moving the -lookback window back or forward by this much with the arrow keys, e.g. 1h, with its bounds in the caption")
+  flag.BoolVar(&options.probe, "probe", false, "Print one JSON object with ok, latest, min, max, mean, datapoints, and whether the latest value breached -threshold, instead of rendering. Exits 0 with any failure to reach or fetch the metric in its error field; invalid or conflicting flags still exit nonzero with the usual message.")
+  flag.StringVar(&options.gnuplotFile, "gnuplot-file", "", "With -output gnuplot, write the data to BASE.dat and the script plotting it to BASE.gp instead of printing the script with its data inline")
+  flag.StringVar(&options.fromFile, "from-file", "", "Draw JSON datapoints saved by -output jsonl or -save-baseline instead of fetching from CloudWatch")
+  flag.StringVar(&options.archive, "archive", "", "Draw the -metric from CloudWatch metric stream records in JSON format archived at this file, directory, or s3://bucket/prefix, with the window ending at the newest record")
+  flag.StringVar(&options.socket, "socket", "", "With -tail, stream datapoints as JSON lines to every client connected to a Unix socket at this path instead of rendering")
+  timeFormatPtr := flag.String("time-format", "", "Layout for timestamps in captions and messages: kitchen, rfc3339, rfc1123, datetime, time, or a Go layout like 2006-01-02 15:04 (defaults to time with -tail, otherwise rfc3339)")
+  flag.BoolVar(&quiet, "quiet", false, "Suppress warnings and status messages; stdout only ever carries the requested output")
+  flag.BoolVar(&redact, "redact", false, "Replace the namespace and metric names in the graph with placeholders and hide timestamps, e.g. for screen-sharing")
+  flag.BoolVar(&options.info, "info", false, "Print additional detail, e.g. how many buckets each fetch received, or the timestamp alongside -output last")
+  flag.DurationVar(&options.compare, "compare", 0, "Also fetch the same window this long ago, e.g. 24h to compare against yesterday")
+  flag.StringVar(&options.compareMode, "compare-mode", compareOverlay, "How to show -compare: overlay both windows, ratio (% change), or delta (difference)")
+  compareRegionsPtr := flag.String("compare-regions", "", "Show the -metric's latest (or -top-by max) value in each of these comma-separated regions, colored by deviation from the median region")
+  compareNamespacesPtr := flag.String("compare-namespaces", "", "Graph the -metric from exactly two namespaces, e.g. MyApp/Blue,MyApp/Green, alongside their difference")
+  flag.StringVar(&options.saveBaseline, "save-baseline", "", "Save the fetched series to this file, for a later -baseline")
+  baselinePtr := flag.String("baseline", "", "Overlay the series saved by -save-baseline in this file, aligned on time since the start of the window")
+  flag.Var(&options.regressionThreshold, "regression-threshold", "With -baseline, check instead of rendering, exiting with status 8 if the current window is more than this percent above the baseline")
+  flag.StringVar(&options.regressionStat, "regression-stat", "mean", "What -regression-threshold compares: mean, max, or p95")
+  flag.DurationVar(&options.detectFlatline, "detect-flatline", 0, "Check instead of rendering whether each metric's datapoints have been unchanged for at least this long, e.g. 30m, and print where it went flat")
+  flag.BoolVar(&options.failOnFlatline, "fail-on-flatline", false, "With -detect-flatline, exit with status 9 if any metric is flat")
+  flag.DurationVar(&options.displayPeriod, "display-period", 0, "Re-bucket the fetched series into coarser buckets of this width before rendering, e.g. 5m")
+  flag.StringVar(&options.displayAggregate, "display-aggregate", "avg", "How -display-period combines buckets: " + aggregationNames)
+  flag.DurationVar(&options.resampleTo, "resample-to", 0, "Re-bucket the datapoints CloudWatch returned into fixed intervals of this width, e.g. 5m, for metrics published irregularly")
+  flag.StringVar(&options.resampleAggregate, "resample-aggregate", "avg", "How -resample-to combines datapoints: " + aggregationNames)
+  flag.BoolVar(&options.top, "top", false, "List every series matching -metric (which may be a glob) and -dimension, ranked by -top-by")
+  flag.StringVar(&options.topBy, "top-by", topByLatest, "What -top ranks series by: latest or max")
+  flag.BoolVar(&options.grid, "grid", false, "Draw every series matching -metric (which may be a glob) or -group-by as a grid of labelled sparklines filling the terminal")
+  flag.Var(&options.yMin, "ymin", "Fix the bottom of the y-axis at this value so it doesn't rescale between renders (data below it still widens the axis)")
+  flag.Var(&options.yMax, "ymax", "Fix the top of the y-axis at this value, e.g. a known capacity (data above it still widens the axis)")
+  captionTemplatePtr := flag.String("caption-template", "", "Go text/template for each graph's caption, with {{.Namespace}}, {{.Metric}}, {{.Stat}}, {{.Lookback}}, {{.End}}, {{.Min}}, and {{.Max}}")
+  eventsPtr := flag.String("events", "", "File of `timestamp,label` lines, e.g. deploys and incidents, to mark on the graph")
+  flag.Var(&options.threshold, "threshold", "Report what percentage of datapoints breach this value in the summary")
+  flag.BoolVar(&options.thresholdBelow, "threshold-below", false, "Count datapoints below -threshold as breaching, rather than above")
+  flag.BoolVar(&options.thresholdIncludeFilled, "threshold-include-filled", false, "Count gap-filled buckets towards the -threshold percentage")
+  flag.StringVar(&options.expression, "expression", "", "Metric math expression to graph instead of the metrics themselves, referring to each -metric as m1, m2, ... e.g. `m1/m2*100`")
+  flag.Var(&options.alertAbove, "alert-above", "Poll instead of rendering, printing an alert and exiting with status 7 once the latest value goes above this")
+  flag.BoolVar(&options.alertContinue, "alert-continue", false, "Keep polling after -alert-above fires rather than exiting")
+  flag.DurationVar(&options.pollInterval, "poll-interval", time.Minute, "How often -alert-above polls")
+  flag.DurationVar(&options.waitForData, "wait-for-data", 0, "If the metric has no data yet, e.g. just after a deploy, keep polling this long for the first datapoint before giving up")
+  flag.BoolVar(&options.dropLast, "drop-last", false, "Hide the final bucket if its period hasn't finished yet. CloudWatch reports partial periods, which look like a sudden drop.")
+  flag.Var(&options.align, "align", "Snap the window to -period boundaries so every bucket is complete and repeated runs return the same bucket timestamps, or with -align='cron:0 * * * *' to the last tick of a cron schedule so windows line up with scheduled jobs")
+  flag.BoolVar(&options.stacked, "stacked", false, "Render each -metric in its own panel, stacked vertically, each scaled to its own data over a shared time range")
+  flag.BoolVar(&options.dumpResponse, "dump-response", false, "Print each raw GetMetricStatistics response as JSON to stderr, e.g. for bug reports")
+  flag.StringVar(&options.api, "api", apiAuto, "CloudWatch API to fetch metrics with: statistics (GetMetricStatistics per metric), data (GetMetricData, batching up to 500 metrics per request), or auto (data for more than one metric)")
+  flag.IntVar(&options.parallelism, "parallelism", 2, "Number of parallel sub-requests to split a rejected request into")
+  flag.StringVar(&options.onOverflow, "on-overflow", overflowSplit, "When a request asks for more datapoints than CloudWatch returns at once: split it into -parallelism requests, or coarsen the period to fit in one (cheaper, at lower resolution)")
+  flag.Float64Var(&options.rateLimit, "rate-limit", 0, "Most GetMetricStatistics and GetMetricData calls to make per second, including splits and retries (0 is unlimited)")
+  flag.BoolVar(&options.failOnEmpty, "fail-on-empty", false, "Exit with status 2 if any series has no real datapoints in the window, only gap-filled buckets. Without it an empty window is a warning and exits 0, as it is in modes that carry on without data like -alert-above, -top, or -output jsonl -tail; -tail with nothing to follow still exits 2")
+  flag.StringVar(&options.selfMetrics, "self-metrics", "", "Publish how many GetMetricStatistics and GetMetricData calls this run made, and how long they took, to this custom namespace with PutMetricData (needs cloudwatch:PutMetricData)")
+  flag.IntVar(&options.benchmark, "benchmark", 0, "Fetch this many times and report latency instead of rendering")
+  flag.StringVar(&options.serveGraph, "serve-graph", "", "Serve the graph as text/plain on this address, e.g. :8080 for 127.0.0.1:8080, with no authentication (query: ?metric=&lookback=&width=&height=, within -namespace)")
+  flag.DurationVar(&options.discoveryCacheTTL, "discovery-cache-ttl", 0, "Cache metric discovery (ListMetrics) results on disk for this long, e.g. 1h (0 disables)")
+  flag.BoolVar(&options.noCache, "no-cache", false, "Ignore cached discovery results and fetch fresh ones")
+  flag.BoolVar(&options.includeLinkedAccounts, "include-linked-accounts", false, "In a CloudWatch monitoring account, also discover metrics from linked source accounts (ListMetrics omits them otherwise)")
+  flag.BoolVar(&options.recentlyActive, "recently-active", false, "Only discover metrics with datapoints in the last 3 hours, the only window ListMetrics can filter by, so long-dead ones don't match globs, -group-by, or -namespace-prefix")
+  flag.StringVar(&options.namespacePrefix, "namespace-prefix", "", "Discover the -metric (which may be a glob) in every namespace starting with this, e.g. MyApp/, instead of only -namespace")
+  flag.BoolVar(&options.variableResolution, "variable-resolution", false, "Fetch data older than 3h at 5 minute and older than 24h at 1 hour resolution, keeping long windows cheap")
+  flag.StringVar(&options.region, "region", "", "AWS region (defaults to AWS_REGION, then instance metadata, then us-east-1)")
+  flag.BoolVar(&options.doctor, "doctor", false, "Check credentials, region, CloudWatch access, and the terminal, then exit")
+  flag.BoolVar(&options.describe, "describe", false, "Print what each -metric means (for common AWS metrics), its dimensions, unit, and how much of the window has data, then exit")
+  flag.BoolVar(&options.fips, "fips", false, "Use the CloudWatch FIPS endpoint for the region")
+  flag.StringVar(&options.proxy, "proxy", "", "HTTP(S) proxy URL for AWS traffic (defaults to HTTPS_PROXY/HTTP_PROXY)")
+  flag.BoolVar(&options.insecureSkipVerify, "insecure-skip-verify", false, "Skip TLS certificate verification, e.g. behind a TLS-intercepting proxy (dangerous)")
+  flag.StringVar(&options.credentialsCommand, "credentials-command", "", "Command printing credentials as JSON ({\"Version\": 1, \"AccessKeyId\", \"SecretAccessKey\", \"SessionToken\", \"Expiration\"}), re-run when they expire, e.g. for a credential broker")
+  flag.StringVar(&options.accessKey, "access-key", "", "AWS access key ID for static credentials (insecure, prefer env/profile)")
+  flag.StringVar(&options.secretKey, "secret-key", "", "AWS secret access key for static credentials (insecure, prefer env/profile)")
+  flag.StringVar(&options.sessionToken, "session-token", "", "AWS session token for static credentials (insecure, prefer env/profile)")
   flag.Parse()
+  // -align is a bool flag, so the flag package leaves a schedule given after a space as a stray argument
+  if flag.NArg() > 0 && strings.HasPrefix(flag.Arg(0), "cron:") {
+    return options, fmt.Errorf("give the schedule as -align=%q, with an equals sign", flag.Arg(0))
+  }
+  explicit := explicitFlags()
+  if err := applyLocalDefaults(localDefaultsFile, explicit); err != nil {
+    return options, err
+  }
+  if *fromInstanceTagsPtr != "" {
+    tagged, err := applyInstanceTags(options, *fromInstanceTagsPtr, explicit)
+    if err != nil {
+      return options, err
+    }
+    options = tagged
+  }
+
+  switch preset, ok := timeFormatPresets[*timeFormatPtr]; {
+  case ok:
+    timeLayout = preset
+  case *timeFormatPtr != "":
+    timeLayout = *timeFormatPtr
+  case options.tail:
+    timeLayout = timeFormatPresets["time"]
+  }
+
+  if len(options.metrics) == 0 {
+    options.metrics = stringList{ "scheduled-charge-due-or-cdq-lte-30|updated" }
+  }
+  if options.diff && len(options.metrics) != 2 {
+    return options, fmt.Errorf("-diff requires exactly two -metric flags, got %d", len(options.metrics))
+  }
+  if options.compareMetrics && len(options.metrics) != 2 {
+    return options, fmt.Errorf("-compare-metrics requires exactly two -metric flags, got %d", len(options.metrics))
+  }
+  if options.compareMetrics && (options.diff || options.stacked || options.expression != "" || options.compare > 0 || options.top || options.band != "" || options.dashboard != "" || options.stack != "" || options.groupBy != "" || options.logGroup != "") {
+    return options, fmt.Errorf("-compare-metrics can't be combined with -diff, -stacked, -expression, -compare, -top, -band, -dashboard, -stack, -group-by, or -log-group")
+  }
+  // The other outputs only carry each panel's own series, which would lose the second metric
+  if options.compareMetrics && (options.socket != "" || (options.output != "graph" && options.output != "chartjson" && options.output != "gnuplot")) {
+    return options, fmt.Errorf("-compare-metrics only works with -output graph, chartjson, or gnuplot")
+  }
+  if *compareStatPtr != "" {
+    options.compareStats = strings.Split(*compareStatPtr, ",")
+    if len(options.compareStats) != 2 || options.compareStats[0] == options.compareStats[1] {
+      return options, fmt.Errorf("-compare-stat takes exactly two different comma-separated statistics, got %q", *compareStatPtr)
+    }
+    for _, stat := range options.compareStats {
+      if !validStat(stat) {
+        return options, fmt.Errorf("unknown -compare-stat statistic %q", stat)
+      }
+    }
+    if explicit["stat"] {
+      return options, fmt.Errorf("-compare-stat picks the statistics itself, so give it or -stat but not both")
+    }
+    if options.diff || options.expression != "" || options.compare > 0 || options.compareMetrics || options.band != "" || options.derive != "" || options.top || options.grid || options.dashboard != "" || *compareNamespacesPtr != "" || *compareRegionsPtr != "" || options.logGroup != "" || options.archive != "" {
+      return options, fmt.Errorf("-compare-stat can't be combined with -diff, -expression, -compare, -compare-metrics, -band, -derive, -top, -grid, -dashboard, -compare-namespaces, -compare-regions, -log-group, or -archive")
+    }
+    // The other outputs only carry each panel's own series, which would lose the second statistic
+    if options.socket != "" || (options.output != "graph" && options.output != "chartjson" && options.output != "gnuplot") {
+      return options, fmt.Errorf("-compare-stat only works with -output graph, chartjson, or gnuplot")
+    }
+    options.stat = options.compareStats[0]
+  }
+  if !validOutput(options.output) {
+    return options, fmt.Errorf("unknown -output %q, expected one of %s", options.output, strings.Join(outputFormats, ", "))
+  }
+  if (options.output == "last" || options.output == "chartjson" || options.output == "gnuplot" || options.output == "values" || options.output == "openmetrics") && options.tail {
+    return options, fmt.Errorf("-output %s can't be combined with -tail", options.output)
+  }
+
+  if options.gnuplotFile != "" && options.output != "gnuplot" {
+    return options, fmt.Errorf("-gnuplot-file requires -output gnuplot")
+  }
+
+  if options.socket != "" && !options.tail {
+    return options, fmt.Errorf("-socket requires -tail")
+  }
+
+  if (options.logGroup == "") != (options.query == "") {
+    return options, fmt.Errorf("-log-group and -query must be provided together")
+  }
+
+  if _, ok := aggregations[options.displayAggregate]; !ok {
+    return options, fmt.Errorf("unknown -display-aggregate %q, expected %s", options.displayAggregate, aggregationNames)
+  }
+  if _, ok := aggregations[options.resampleAggregate]; !ok {
+    return options, fmt.Errorf("unknown -resample-aggregate %q, expected %s", options.resampleAggregate, aggregationNames)
+  }
+  if options.resampleTo < 0 {
+    return options, fmt.Errorf("-resample-to must not be negative")
+  }
+  if options.resampleTo > 0 && options.displayPeriod > 0 {
+    return options, fmt.Errorf("-resample-to and -display-period both re-bucket the series; use one or the other")
+  }
+  if options.yMin.set && options.yMax.set && options.yMin.value >= options.yMax.value {
+    return options, fmt.Errorf("-ymin must be less than -ymax")
+  }
+  if *captionTemplatePtr != "" {
+    caption, err := parseCaptionTemplate(*captionTemplatePtr)
+    if err != nil {
+      return options, err
+    }
+    options.captionTemplate = caption
+  }
+  if options.compare < 0 {
+    return options, fmt.Errorf("-compare must be a positive duration like 24h")
+  }
+  if options.compareMode != compareOverlay && options.compareMode != compareRatio && options.compareMode != compareDelta {
+    return options, fmt.Errorf("unknown -compare-mode %q, expected %s, %s, or %s", options.compareMode, compareOverlay, compareRatio, compareDelta)
+  }
+  if *compareRegionsPtr != "" {
+    options.compareRegions = strings.Split(*compareRegionsPtr, ",")
+    if len(options.compareRegions) < 2 {
+      return options, fmt.Errorf("-compare-regions takes at least two comma-separated regions")
+    }
+    if len(options.metrics) != 1 || isGlob(options.metrics[0]) {
+      return options, fmt.Errorf("-compare-regions takes exactly one -metric, and not a glob")
+    }
+    if options.diff || options.stacked || options.stack != "" || options.expression != "" || options.compare > 0 || options.top || options.grid || options.logGroup != "" || options.dashboard != "" || *compareNamespacesPtr != "" || options.groupBy != "" || options.output != "graph" {
+      return options, fmt.Errorf("-compare-regions can't be combined with -diff, -stacked, -stack, -expression, -compare, -top, -grid, -log-group, -dashboard, -compare-namespaces, -group-by, or -output")
+    }
+  }
+  if *compareNamespacesPtr != "" {
+    options.compareNamespaces = strings.Split(*compareNamespacesPtr, ",")
+    if len(options.compareNamespaces) != 2 || options.compareNamespaces[0] == "" || options.compareNamespaces[1] == "" {
+      return options, fmt.Errorf("-compare-namespaces takes exactly two comma-separated namespaces, got %q", *compareNamespacesPtr)
+    }
+    if len(options.metrics) != 1 || isGlob(options.metrics[0]) {
+      return options, fmt.Errorf("-compare-namespaces takes exactly one -metric, and not a glob")
+    }
+    if options.diff || options.stacked || options.stack != "" || options.expression != "" || options.compare > 0 || options.top || options.logGroup != "" || options.dashboard != "" {
+      return options, fmt.Errorf("-compare-namespaces can't be combined with -diff, -stacked, -stack, -expression, -compare, -top, -log-group, or -dashboard")
+    }
+  }
+  if options.compare > 0 && options.diff {
+    return options, fmt.Errorf("-compare can't be combined with -diff")
+  }
+  if options.groupBy != "" && (options.diff || options.stack != "" || options.expression != "" || options.top || options.dashboard != "" || len(options.compareNamespaces) > 0 || options.logGroup != "") {
+    return options, fmt.Errorf("-group-by can't be combined with -diff, -stack, -expression, -top, -dashboard, -compare-namespaces, or -log-group")
+  }
+  if options.groupBy != "" {
+    for _, metric := range options.metrics {
+      if isGlob(metric) {
+        return options, fmt.Errorf("-group-by can't be combined with a -metric glob")
+      }
+    }
+  }
+  if options.namespacePrefix != "" && (options.stack != "" || options.groupBy != "" || options.dashboard != "" || len(options.compareNamespaces) > 0 || options.logGroup != "") {
+    return options, fmt.Errorf("-namespace-prefix can't be combined with -stack, -group-by, -dashboard, -compare-namespaces, or -log-group")
+  }
+  if options.groupBy != "" && options.groupByLimit < 1 {
+    return options, fmt.Errorf("-group-by-limit must be at least 1")
+  }
+  if options.diff && options.stack != "" {
+    return options, fmt.Errorf("-diff can't be combined with -stack")
+  }
+  if options.diff && options.stacked {
+    return options, fmt.Errorf("-diff and -stacked are mutually exclusive")
+  }
+  if !options.diff && !options.compareMetrics && !options.stacked && !options.top && !options.grid && options.expression == "" && len(options.metrics) > 1 {
+    return options, fmt.Errorf("multiple -metric flags are only supported with -diff, -compare-metrics, -stacked, -top, -grid, or -expression")
+  }
+  if options.expression != "" && (options.diff || options.stacked || options.top || options.compare > 0 || options.stack != "") {
+    return options, fmt.Errorf("-expression can't be combined with -diff, -stacked, -top, -compare, or -stack")
+  }
+  if options.compactGaps < 0 {
+    return options, fmt.Errorf("-compact-gaps must not be negative")
+  }
+  if options.waitForData < 0 {
+    return options, fmt.Errorf("-wait-for-data must not be negative")
+  }
+  if options.onOverflow != overflowSplit && options.onOverflow != overflowCoarsen {
+    return options, fmt.Errorf("unknown -on-overflow %q, expected %s or %s", options.onOverflow, overflowSplit, overflowCoarsen)
+  }
+  if options.onUpdate != "" && (!options.tail || options.output != "graph" || options.socket != "" || options.top || options.logGroup != "" || len(options.compareRegions) > 0) {
+    return options, fmt.Errorf("-on-update requires -tail, and can't be combined with -output, -socket, -top, -log-group, or -compare-regions")
+  }
+  if options.tailHistoryLimit < 0 {
+    return options, fmt.Errorf("-tail-history-limit must not be negative")
+  }
+  if options.pollInterval <= 0 {
+    return options, fmt.Errorf("-poll-interval must be positive")
+  }
+  if options.topBy != topByLatest && options.topBy != topByMax {
+    return options, fmt.Errorf("unknown -top-by %q, expected %s or %s", options.topBy, topByLatest, topByMax)
+  }
+
+
+
+  lookback, err := parseLookback(*lookbackPtr)
+  if err != nil {
+    return options, fmt.Errorf("invalid -lookback: %w", err)
+  }
+  options.lookback = lookback
+  if *sinceDeployPtr != "" {
+    options.deployedAt, err = readDeployMarker(*sinceDeployPtr)
+    if err != nil {
+      return options, err
+    }
+    options.lookback = -time.Since(options.deployedAt)
+  }
+  if *sincePtr != "" {
+    if explicit["lookback"] || *sinceDeployPtr != "" || options.points != 0 {
+      return options, fmt.Errorf("-since sets the start of the window, so it can't be combined with -lookback, -since-deploy, or -points")
+    }
+    start, err := parseSince(*sincePtr, time.Now())
+    if err != nil {
+      return options, fmt.Errorf("%w: %s", ErrInvalidWindow, err.Error())
+    }
+    if !start.Before(time.Now()) {
+      return options, fmt.Errorf("%w: -since %q is in the future", ErrInvalidWindow, *sincePtr)
+    }
+    options.lookback = -time.Since(start)
+  }
+  if options.points != 0 {
+    if explicit["lookback"] || *sinceDeployPtr != "" || options.autoPeriod {
+      return options, fmt.Errorf("-points sets the window from -period, so it can't be combined with -lookback, -since-deploy, or -auto-period")
+    }
+    if options.points < 0 || options.points > maxDatapointsPerRequest {
+      return options, fmt.Errorf("%w: -points must be between 1 and %d, the most CloudWatch returns per request", ErrInvalidWindow, maxDatapointsPerRequest)
+    }
+    options.lookback = -time.Duration(options.points * options.period) * time.Second
+  }
 
-  if !strings.HasPrefix(*lookbackPtr, "-") {
-    *lookbackPtr = "-" + *lookbackPtr
+  if options.autoPeriod {
+    if explicit["period"] {
+      return options, fmt.Errorf("-auto-period picks -period itself, so give one or the other")
+    }
+    // Sized for everything fetched, which with -compare includes the earlier window overlaid on this one
+    options.period = autoPeriod(-options.lookback + options.compare)
+    if options.info {
+      infof("Using a %ds period for the %s window", options.period, -options.lookback)
+    }
+  }
+  if *multiLookbackPtr != "" {
+    if explicit["lookback"] || *sincePtr != "" || *sinceDeployPtr != "" || options.points != 0 || options.autoPeriod {
+      return options, fmt.Errorf("-multi-lookback sets the windows itself, so it can't be combined with -lookback, -since, -since-deploy, -points, or -auto-period")
+    }
+    longest := time.Duration(0)
+    for _, raw := range strings.Split(*multiLookbackPtr, ",") {
+      lookback, err := parseLookback(raw)
+      if err != nil {
+        return options, fmt.Errorf("invalid -multi-lookback window %q: %w", raw, err)
+      }
+      options.multiLookback = append(options.multiLookback, -lookback)
+      if -lookback > longest {
+        longest = -lookback
+      }
+    }
+    if len(options.multiLookback) < 2 {
+      return options, fmt.Errorf("-multi-lookback takes at least two comma-separated windows, e.g. 1h,24h")
+    }
+    for _, window := range options.multiLookback {
+      period := options.period
+      if !explicit["period"] {
+        period = autoPeriod(window)
+      }
+      options.multiLookbackPeriods = append(options.multiLookbackPeriods, period)
+    }
+    // The checks below then hold the longest window to its retention
+    options.lookback = -longest
+    if !explicit["period"] {
+      options.period = autoPeriod(longest)
+    }
+  }
+  if options.snapshotKeep < 0 || (options.snapshotKeep > 0 && options.snapshot == "") {
+    return options, fmt.Errorf("-snapshot-keep must be positive and requires -snapshot")
+  }
+  if options.detectFlatline > -options.lookback {
+    return options, fmt.Errorf("%w: -detect-flatline %s is longer than the %s window, so no flatline could be found", ErrInvalidWindow, options.detectFlatline, -options.lookback)
+  }
+  if !validPeriod(options.period) {
+    return options, fmt.Errorf("invalid -period %d, CloudWatch accepts 1, 5, 10, 30, or a multiple of 60", options.period)
+  }
+  if *windowStepPtr != "" {
+    if options.windowStep, err = parseDuration(*windowStepPtr); err != nil || options.windowStep <= 0 {
+      return options, fmt.Errorf("invalid -window-step %q, expected a positive duration like 1h", *windowStepPtr)
+    }
+    if options.windowStep % (time.Duration(options.period) * time.Second) != 0 {
+      return options, fmt.Errorf("-window-step must be a multiple of -period, so stepping keeps the buckets aligned")
+    }
+  }
+  if err := checkExclusiveModes(options.modeFlags()); err != nil {
+    return options, err
+  }
+  if options.displayPeriod != 0 && (options.displayPeriod < time.Duration(options.period) * time.Second || options.displayPeriod % (time.Duration(options.period) * time.Second) != 0) {
+    return options, fmt.Errorf("-display-period must be a multiple of -period")
+  }
+  if *maxWindowPtr != "" {
+    if options.maxWindow, err = parseDuration(*maxWindowPtr); err != nil || options.maxWindow <= 0 {
+      return options, fmt.Errorf("invalid -max-window %q, expected a positive duration like 14d", *maxWindowPtr)
+    }
+  }
+  // Archives hold what CloudWatch has long since dropped
+  if options.logGroup == "" && options.archive == "" {
+    if err := checkRetention(options); err != nil {
+      return options, err
+    }
+  }
+  if !validStat(options.stat) {
+    return options, fmt.Errorf("unknown -stat %q", options.stat)
+  }
+  if options.derive != "" && options.derive != deriveAverage {
+    return options, fmt.Errorf("unknown -derive %q, expected %s", options.derive, deriveAverage)
+  }
+  if options.derive == deriveAverage && options.stat != cloudwatch.StatisticSampleCount {
+    return options, fmt.Errorf("-derive average needs -stat SampleCount, since it's computed from SampleCount and Sum")
+  }
+  if options.minSamples < 0 {
+    return options, fmt.Errorf("-min-samples must not be negative")
+  }
+  if options.minSamples > 0 && options.stat != cloudwatch.StatisticAverage {
+    return options, fmt.Errorf("-min-samples needs -stat Average")
+  }
+  if options.minSamples > 0 && (options.api == apiMetricData || options.expression != "") {
+    return options, fmt.Errorf("-min-samples needs the sample count of each bucket, which GetMetricData can't return alongside the average; it can't be combined with -api %s or -expression", apiMetricData)
+  }
+  if _, ok := bands[options.band]; options.band != "" && !ok {
+    return options, fmt.Errorf("unknown -band %q, expected stddev or iqr", options.band)
+  }
+  if options.band != "" && options.stat != cloudwatch.StatisticAverage {
+    return options, fmt.Errorf("-band needs -stat Average")
+  }
+  if options.band != "" && (options.diff || options.expression != "" || options.compare > 0 || options.dashboard != "" || len(options.compareNamespaces) > 0) {
+    return options, fmt.Errorf("-band can't be combined with -diff, -expression, -compare, -dashboard, or -compare-namespaces")
+  }
+  if _, ok := normalizations[options.normalize]; options.normalize != "" && !ok {
+    return options, fmt.Errorf("unknown -normalize %q, expected maxpct or zscore", options.normalize)
+  }
+  // The band's edges only mean something relative to the mean, which normalizing each separately would lose
+  if options.normalize != "" && options.band != "" {
+    return options, fmt.Errorf("-normalize can't be combined with -band")
+  }
+  if options.highlightZScore < 0 {
+    return options, fmt.Errorf("-highlight-zscore must not be negative")
+  }
+  if options.zscoreWindow < minZScoreSamples {
+    return options, fmt.Errorf("-zscore-window must be at least %d", minZScoreSamples)
+  }
+  // Both rescale each series on its own, which would move the highlighted points off the line they belong to
+  if options.highlightZScore > 0 && (options.normalize != "" || options.dualAxis) {
+    return options, fmt.Errorf("-highlight-zscore can't be combined with -normalize or -dual-axis")
+  }
+  if options.dualAxis && (options.normalize != "" || options.band != "") {
+    return options, fmt.Errorf("-dual-axis can't be combined with -normalize or -band")
+  }
+  if options.api != apiAuto && options.api != apiStatistics && options.api != apiMetricData {
+    return options, fmt.Errorf("unknown -api %q, expected %s, %s, or %s", options.api, apiAuto, apiStatistics, apiMetricData)
+  }
+  if options.api == apiMetricData && (options.derive != "" || options.variableResolution) {
+    return options, fmt.Errorf("-api %s can't be combined with -derive or -variable-resolution", apiMetricData)
   }
-  lookback, err := time.ParseDuration(*lookbackPtr)
+  if options.derive != "" && options.expression != "" {
+    return options, fmt.Errorf("-derive can't be combined with -expression; divide the Sum and SampleCount in the expression instead")
+  }
+  if options.parallelism < 2 {
+    return options, fmt.Errorf("-parallelism must be at least 2")
+  }
+  if options.dashboard != "" && (options.diff || options.expression != "" || options.compare > 0 || options.stack != "" || options.top || options.logGroup != "" || options.alertAbove.set || options.serveGraph != "" || options.output != "graph") {
+    return options, fmt.Errorf("-dashboard can't be combined with -diff, -expression, -compare, -stack, -top, -log-group, -alert-above, -serve-graph, or -output")
+  }
+  if options.selfMetrics != "" && !validSelfMetricsNamespace(options.selfMetrics) {
+    return options, fmt.Errorf("invalid -self-metrics %q, namespaces starting with AWS/ are reserved", options.selfMetrics)
+  }
+  if options.selfMetrics != "" && options.fromFile != "" {
+    return options, fmt.Errorf("-from-file makes no API calls, so there's nothing for -self-metrics to report")
+  }
+  if options.rateLimit < 0 {
+    return options, fmt.Errorf("-rate-limit must not be negative")
+  }
+  if options.benchmark < 0 {
+    return options, fmt.Errorf("-benchmark must not be negative")
+  }
+
+  if options.fill != fillZero && options.fill != fillNone && options.fill != fillRepeat {
+    return options, fmt.Errorf("unknown -fill %q, expected %s, %s, or %s", options.fill, fillZero, fillRepeat, fillNone)
+  }
+  if options.maxGap < 0 {
+    return options, fmt.Errorf("-max-gap must not be negative")
+  }
+
+  if err := validateStaticCredentials(options); err != nil {
+    return options, err
+  }
+
+  if *eventsPtr != "" {
+    options.events, err = loadEvents(*eventsPtr)
+    if err != nil {
+      return options, err
+    }
+  }
+  if !options.deployedAt.IsZero() {
+    options.events = append(options.events, deployEvent(options.deployedAt))
+  }
+  if *baselinePtr != "" {
+    options.baseline, err = loadBaseline(*baselinePtr)
+    if err != nil {
+      return options, err
+    }
+  }
+  if options.regressionThreshold.set && *baselinePtr == "" {
+    return options, fmt.Errorf("-regression-threshold requires -baseline")
+  }
+  if _, ok := regressionStats[options.regressionStat]; !ok {
+    return options, fmt.Errorf("unknown -regression-stat %q, expected mean, max, or p95", options.regressionStat)
+  }
+  if options.regressionThreshold.set && (options.tail || options.output != "graph" || options.top || options.grid || options.logGroup != "" || options.dashboard != "" || options.alertAbove.set || options.serveGraph != "" || options.archive != "") {
+    return options, fmt.Errorf("-regression-threshold can't be combined with -tail, -output, -top, -grid, -log-group, -dashboard, -alert-above, -serve-graph, or -archive")
+  }
+  if options.detectFlatline < 0 {
+    return options, fmt.Errorf("-detect-flatline must not be negative")
+  }
+  if options.failOnFlatline && options.detectFlatline == 0 {
+    return options, fmt.Errorf("-fail-on-flatline requires -detect-flatline")
+  }
+  if options.detectFlatline > 0 && (options.tail || options.output != "graph" || options.top || options.grid || options.regressionThreshold.set || options.logGroup != "" || options.expression != "" || options.compareMetrics || options.band != "" || options.alertAbove.set || options.serveGraph != "" || options.archive != "") {
+    return options, fmt.Errorf("-detect-flatline can't be combined with -tail, -output, -top, -grid, -regression-threshold, -log-group, -expression, -compare-metrics, -band, -alert-above, -serve-graph, or -archive")
+  }
+  if options.dashboard != "" {
+    options.dashboardPanels, err = loadDashboard(options.dashboard, options)
+    if err != nil {
+      return options, err
+    }
+  }
+
+  dimensions, err := parseDimensions(options.dimensions)
   if err != nil {
-    fmt.Println("Failed to parse lookback:", err.Error())
-    return *metric, *namespace, lookback, *tail, nil
+    return options, err
   }
-  
-  return *metric, *namespace, lookback, *tail, nil
+  for _, metric := range options.metrics {
+    options.queries = append(options.queries, MetricQuery{
+      Metric: metric,
+      Namespace: options.namespace,
+      Dimensions: dimensions,
+      Period: options.period,
+      Stat: options.stat,
+      Derive: options.derive,
+    })
+  }
+
+  return options, nil
+}
+
+// Lookbacks are always into the past, so the leading minus is optional
+func parseLookback(raw string) (time.Duration, error) {
+  if !strings.HasPrefix(raw, "-") {
+    raw = "-" + raw
+  }
+  lookback, err := parseDuration(raw)
+  if err != nil {
+    return lookback, err
+  }
+  if lookback == 0 {
+    return lookback, fmt.Errorf("%w: lookback must be non-zero", ErrInvalidWindow)
+  }
+
+  return lookback, nil
 }
 
-func createClient() Client {
-  region := "us-east-1"
-  sess := session.Must(session.NewSessionWithOptions(session.Options{
+// All three credential flags must be given together, since a partial set would silently fall back to the default chain
+func validateStaticCredentials(options Options) error {
+  provided := 0
+  for _, value := range []string{ options.accessKey, options.secretKey, options.sessionToken } {
+    if value != "" {
+      provided++
+    }
+  }
+
+  if provided != 0 && provided != 3 {
+    return fmt.Errorf("-access-key, -secret-key, and -session-token must be provided together")
+  }
+  if provided != 0 && options.credentialsCommand != "" {
+    return fmt.Errorf("-credentials-command can't be combined with -access-key, -secret-key, and -session-token")
+  }
+
+  return nil
+}
+
+func validPeriod(period int64) bool {
+  switch period {
+  case 1, 5, 10, 30:
+    return true
+  }
+
+  return period > 0 && period % 60 == 0
+}
+
+func (options Options) fillPolicy() FillPolicy {
+  return FillPolicy{ Strategy: options.fill, MaxGap: options.maxGap, TrimEdges: options.trimZeros }
+}
+
+func (options Options) hasStaticCredentials() bool {
+  return options.accessKey != "" && options.secretKey != "" && options.sessionToken != ""
+}
+
+func createClient(options Options) (Client, error) {
+  region := resolveRegion(options)
+  if options.hasStaticCredentials() {
+    warnf("Warning: passing secrets on the command line is insecure (they end up in shell history and process listings); prefer environment variables or a shared profile")
+  }
+  sess, err := newSession(options, region)
+  if err != nil {
+    return Client{}, err
+  }
+  if options.fips {
+    endpoint, err := fipsEndpoint(region)
+    if err != nil {
+      return Client{}, err
+    }
+    sess.Config.Endpoint = &endpoint
+  }
+
+  client := Client{
+    session: sess,
+    parallelism: options.parallelism,
+    discoveryCacheTTL: options.discoveryCacheTTL,
+    noCache: options.noCache,
+    includeLinkedAccounts: options.includeLinkedAccounts,
+    recentlyActive: options.recentlyActive,
+    namespacePrefix: options.namespacePrefix,
+    onOverflow: options.onOverflow,
+    variableResolution: options.variableResolution,
+    minSamples: options.minSamples,
+    info: options.info,
+    dumpResponse: options.dumpResponse,
+    requestCount: new(int64),
+    dataRequestCount: new(int64),
+    fetchLatency: new(int64),
+    coarsenedPeriods: &sync.Map{},
+    account: &callerAccount{},
+  }
+  if options.rateLimit > 0 {
+    client.limiter = newRateLimiter(options.rateLimit)
+  }
+  client.connection = client.instrument(cloudwatch.New(sess))
+
+  return client, nil
+}
+
+// Count and pace the connection's metric API calls. Send handlers run once per attempt, so retries are counted and paced too.
+func (client Client) instrument(connection *cloudwatch.CloudWatch) *cloudwatch.CloudWatch {
+  connection.Handlers.Send.PushBack(func (r *request.Request) {
+    switch r.Operation.Name {
+    case "GetMetricStatistics":
+      atomic.AddInt64(client.requestCount, 1)
+    case "GetMetricData":
+      atomic.AddInt64(client.dataRequestCount, 1)
+    }
+  })
+  connection.Handlers.CompleteAttempt.PushBack(func (r *request.Request) {
+    if r.Operation.Name == "GetMetricStatistics" || r.Operation.Name == "GetMetricData" {
+      atomic.AddInt64(client.fetchLatency, int64(time.Since(r.AttemptTime)))
+    }
+  })
+  if client.limiter != nil {
+    connection.Handlers.Send.PushFront(func (r *request.Request) {
+      if r.Operation.Name == "GetMetricStatistics" || r.Operation.Name == "GetMetricData" {
+        client.limiter.wait()
+      }
+    })
+  }
+
+  return connection
+}
+
+// Metric API calls sent for a single fetch. The client-wide counts can't be diffed for this, since tail feeds and served requests fetch at the same time.
+type fetchCalls struct {
+  statistics int64
+  data int64
+}
+
+// A request option counting each attempt towards the fetch's own calls, when they're being counted
+func (client Client) countFetchCalls(r *request.Request) {
+  if client.fetchCalls == nil {
+    return
+  }
+  r.Handlers.Send.PushBack(func (r *request.Request) {
+    switch r.Operation.Name {
+    case "GetMetricStatistics":
+      atomic.AddInt64(&client.fetchCalls.statistics, 1)
+    case "GetMetricData":
+      atomic.AddInt64(&client.fetchCalls.data, 1)
+    }
+  })
+}
+
+// A session in region with the run's credentials and proxy, so every AWS call runs as the same identity
+func newSession(options Options, region string) (*session.Session, error) {
+  config := aws.Config{ Region: &region }
+  httpClient, err := newHTTPClient(options)
+  if err != nil {
+    return nil, err
+  }
+  config.HTTPClient = httpClient
+  if options.hasStaticCredentials() {
+    config.Credentials = credentials.NewStaticCredentials(options.accessKey, options.secretKey, options.sessionToken)
+  }
+  if options.credentialsCommand != "" {
+    config.Credentials = processcreds.NewCredentials(options.credentialsCommand)
+  }
+
+  return session.NewSessionWithOptions(session.Options{
     SharedConfigState: session.SharedConfigEnable,
-    Config: aws.Config{ Region: &region },
-  }))
+    Config: config,
+  })
+}
+
+// Route traffic through -proxy if given, otherwise whatever HTTPS_PROXY/HTTP_PROXY/NO_PROXY say
+func newHTTPClient(options Options) (*http.Client, error) {
+  transport := http.DefaultTransport.(*http.Transport).Clone()
+  transport.Proxy = http.ProxyFromEnvironment
+  if options.proxy != "" {
+    proxyURL, err := url.Parse(options.proxy)
+    if err != nil || proxyURL.Scheme == "" || proxyURL.Host == "" {
+      return nil, fmt.Errorf("invalid -proxy %q, expected a URL like http://proxy.internal:3128", options.proxy)
+    }
+    transport.Proxy = http.ProxyURL(proxyURL)
+  }
+
+  if options.insecureSkipVerify {
+    warnf("WARNING: TLS certificate verification is DISABLED. Anyone on the network path can read and tamper with your AWS traffic, including credentials. Only use -insecure-skip-verify behind a proxy you trust.")
+    transport.TLSClientConfig = &tls.Config{ InsecureSkipVerify: true }
+  }
 
-  return Client{ connection: cloudwatch.New(sess) }
+  return &http.Client{ Transport: transport }, nil
 }
 
-func render(data []float64, metric string, namespace string, lookback time.Duration, end time.Time) error {
+// This SDK version has no FIPS toggle, so resolve the region's `fips-` endpoint directly. Strict matching makes regions without one an error instead of a guess.
+func fipsEndpoint(region string) (string, error) {
+  resolved, err := endpoints.DefaultResolver().EndpointFor(cloudwatch.EndpointsID, "fips-" + region, endpoints.StrictMatchingOption)
+  if err != nil {
+    return "", fmt.Errorf("region %s has no CloudWatch FIPS endpoint: %w", region, err)
+  }
+
+  return resolved.URL, nil
+}
+
+func render(panels []Panel, namespace string, lookback time.Duration, end time.Time) error {
   width, height, err := terminal.GetSize(int(os.Stdin.Fd()))
   if err != nil {
-    fmt.Println("Cannot fetch terminal size:", err.Error())
+    errorln("Cannot fetch terminal size:", err.Error())
     return err
   }
 
-  graph := asciigraph.Plot(
-    data,
-    asciigraph.Width(int(float64(width) * 0.98)),
-    asciigraph.Height(int(float64(height) * 0.98)),
-    asciigraph.Caption(fmt.Sprintf("[%s/%s] with lookback=%s (last updated at %s)", namespace, metric, lookback, end)),
-  )
+  if redact {
+    panels, namespace = redactPanels(panels, namespace)
+  }
+  graph := safePlotPanels(panels, namespace, lookback, end, width, height)
   asciigraph.Clear()
   fmt.Println(graph)
 
   return nil
 }
 
+// asciigraph can panic on degenerate series, which mid-tail would throw away the whole session, so fall back to a plain summary of each panel instead
+func safePlotPanels(panels []Panel, namespace string, lookback time.Duration, end time.Time, width int, height int) (graph string) {
+  defer func () {
+    if recovered := recover(); recovered != nil {
+      warnf("Warning: failed to draw the graph (%v), showing a summary instead", recovered)
+      graph = textSummary(panels, namespace, end)
+    }
+  }()
 
-func (client Client) renderMetricSampleCounts(metric string, namespace string, lookback time.Duration, tail bool) error {
-  end := time.Now()
-  start := end.Add(lookback)
-  period := int64(60)
-  sampleCount := cloudwatch.StatisticSampleCount
-  request := cloudwatch.GetMetricStatisticsInput{
-    MetricName: &metric,
-    Namespace: &namespace,
-    StartTime: &start,
-    EndTime: &end,
-    Period: &period,
-    Statistics: []*string{ &sampleCount },
+  return plotPanels(panels, namespace, lookback, end, width, height)
+}
+
+// One line per series with its range and latest value
+func textSummary(panels []Panel, namespace string, end time.Time) string {
+  lines := []string{ fmt.Sprintf("[%s] (last updated at %s)", namespace, formatTime(end)) }
+  for _, panel := range panels {
+    for _, series := range panel.allSeries() {
+      min, max, ok := series.Series.Bounds()
+      last, hasLast := series.Series.Last()
+      if !ok || !hasLast {
+        lines = append(lines, fmt.Sprintf("%s: no data", series.Label))
+        continue
+      }
+      lines = append(lines, fmt.Sprintf("%s: %d points, min %g, max %g, last %g at %s", series.Label, len(series.Series), min, max, last.Value, formatTime(last.Timestamp)))
+    }
+  }
+
+  return strings.Join(lines, "\n")
+}
+
+// Draw the panels to fit a width x height character area
+func plotPanels(panels []Panel, namespace string, lookback time.Duration, end time.Time, width int, height int) string {
+  // Stacked panels split the height evenly, leaving room for footers and each panel's caption and a separating line
+  footerLines := 0
+  for _, panel := range panels {
+    footerLines += len(panel.Footer)
+    if panel.hasColors() {
+      footerLines++
+    }
+    if len(panel.Events) > 0 {
+      footerLines += 2
+    }
+  }
+  panelHeight := (int(float64(height) * 0.98) - footerLines) / len(panels)
+  if len(panels) > 1 {
+    panelHeight -= 2
+  }
+  if panelHeight < 1 {
+    panelHeight = 1
   }
 
-  counts, err := client.getMetricSampleCounts(&request)
+  graphs := []string{}
+  for _, panel := range panels {
+    data := [][]float64{ panel.Series.Values() }
+    combined := append(Series{}, panel.Series...)
+    for _, overlay := range panel.Overlays {
+      data = append(data, overlay.Series.Values())
+      combined = append(combined, overlay.Series...)
+    }
+
+    plotWidth := int(float64(width) * 0.98)
+    plotOptions := []asciigraph.Option{
+      asciigraph.Width(plotWidth),
+      asciigraph.Height(panelHeight),
+      asciigraph.Caption(panel.caption(namespace, lookback, end)),
+    }
+    if lower, upper, ok := zeroAlignedBounds(combined, panelHeight); ok && !panel.YMin.set && !panel.YMax.set {
+      plotOptions = append(plotOptions, asciigraph.LowerBound(lower), asciigraph.UpperBound(upper))
+    }
+    if panel.YMin.set {
+      plotOptions = append(plotOptions, asciigraph.LowerBound(panel.YMin.value))
+    }
+    if panel.YMax.set {
+      plotOptions = append(plotOptions, asciigraph.UpperBound(panel.YMax.value))
+    }
+    if panel.hasColors() {
+      plotOptions = append(plotOptions, asciigraph.SeriesColors(panel.colors()...))
+    }
+    graph := asciigraph.PlotMany(data, plotOptions...)
+    if len(panel.Events) > 0 {
+      graph = markEvents(graph, panel.Series, panel.Events, plotWidth)
+    }
+    graphs = append(graphs, graph)
+    if panel.hasColors() {
+      graphs = append(graphs, panel.legend())
+    }
+    graphs = append(graphs, panel.Footer...)
+  }
+
+  return strings.Join(graphs, "\n")
+}
+
+// For a series crossing zero, widen the bounds just enough that zero falls exactly on a row, so the y-axis labels show the crossing.
+// asciigraph labels row r (from the top) as max - r * (max - min) / height, so zero needs max * height / (max - min) to be a whole number.
+func zeroAlignedBounds(series Series, height int) (float64, float64, bool) {
+  min, max, ok := series.Bounds()
+  if !ok || min >= 0 || max <= 0 || height < 2 {
+    return 0, 0, false
+  }
+
+  zeroRow := max * float64(height) / (max - min)
+  if row := math.Ceil(zeroRow); row < float64(height) {
+    // Raise max until the zero row lands on the next whole row down
+    return min, row * -min / (float64(height) - row), true
+  }
+  // Zero is already on the bottom row, so lower min instead
+  row := math.Floor(zeroRow)
+  return max - max * float64(height) / row, max, true
+}
+
+func (client Client) renderMetricSampleCounts(options Options) error {
+  // A window shorter than a single bucket always comes back empty, so say why rather than drawing nothing
+  if period := time.Duration(options.period) * time.Second; -options.lookback < period {
+    return fmt.Errorf("%w: lookback %s is shorter than the %s period, so no bucket fits in the window; use a longer -lookback or a shorter -period", ErrInvalidWindow, -options.lookback, period)
+  }
+
+  start, end := options.window(time.Now())
+  panels, err := client.fetchPanels(options, start, end)
+  if errors.Is(err, ErrNoData) && options.waitForData > 0 {
+    end, panels, err = client.waitForData(options)
+  }
+  // An empty window is only a failure when asked for, so a quiet metric doesn't look like a broken fetch
+  if errors.Is(err, ErrNoData) && !options.failOnEmpty && !options.tail {
+    warnf("Warning: %v, so there's nothing to draw; pass -fail-on-empty to exit with status 2 instead", err)
+    return nil
+  }
   if err != nil {
     return err
   }
+  if options.saveBaseline != "" {
+    if err := saveBaseline(options.saveBaseline, panels); err != nil {
+      return err
+    }
+  }
+
+  render(transformPanels(options, panels, end), options.namespace, options.lookback, end)
+
+  if options.tail {
+    return client.tail(options, panels, end)
+  }
+
+  return nil
+}
+
+// The window to fetch for a render at now, snapped to period boundaries or cron ticks with -align
+func (options Options) window(now time.Time) (time.Time, time.Time) {
+  end := options.windowEnd(now)
+  start := options.align.snap(end.Add(options.lookback), time.Duration(options.period) * time.Second)
+
+  return start, end
+}
 
-  render(counts, metric, namespace, lookback, end)
+// With -align, the end of the last complete period before now, so the final bucket isn't still being aggregated, or the last cron tick
+func (options Options) windowEnd(now time.Time) time.Time {
+  return options.align.snap(now, time.Duration(options.period) * time.Second)
+}
 
-  if tail {
-    for {
-      time.Sleep(time.Duration(1) * time.Minute)
+// Whether to fetch -metric queries in GetMetricData batches. Derived values and -min-samples need two statistics of each datapoint and variable resolution splits each request, so they stay on GetMetricStatistics.
+func (options Options) batchWithMetricData() bool {
+  if options.compare > 0 || options.derive != "" || options.variableResolution || options.minSamples > 0 {
+    return false
+  }
 
-      // Make new request
-      start = end
-      end = time.Now()
-      newCounts, newErr := client.getMetricSampleCounts(&request)
-      if newErr != nil {
-        return newErr
+  return options.api == apiMetricData || (options.api == apiAuto && len(options.queries) > 1)
+}
+
+// Fetch the panels to display for [start, end): one per metric, or a single panel holding the difference in -diff mode
+func (client Client) fetchPanels(options Options, start time.Time, end time.Time) ([]Panel, error) {
+  if options.failOnEmpty {
+    return client.fetchNonEmptyPanels(options, start, end)
+  }
+  if client.info && client.fetchCalls == nil {
+    client.fetchCalls = &fetchCalls{}
+    defer func () {
+      infof("%d GetMetricStatistics and %d GetMetricData call(s) for this fetch", atomic.LoadInt64(&client.fetchCalls.statistics), atomic.LoadInt64(&client.fetchCalls.data))
+    }()
+  }
+  if len(options.dashboardPanels) > 0 {
+    return client.fetchDashboardPanels(options, start, end)
+  }
+  if len(options.compareNamespaces) > 0 {
+    return client.fetchNamespacePanels(options, options.queries[0], start, end)
+  }
+  if options.diff {
+    return client.fetchDiffPanel(options, start, end)
+  }
+  if options.expression != "" {
+    series, err := client.getExpressionSeries(options, start, end)
+    return []Panel{ { Label: options.expression, Series: series } }, err
+  }
+
+  panels := []Panel{}
+  var batched []Series
+  if options.batchWithMetricData() {
+    var err error
+    if batched, err = client.getMetricDataSeries(options.queries, start, end, options.fillPolicy()); err != nil {
+      return panels, err
+    }
+  }
+  for i, query := range options.queries {
+    if options.compare > 0 {
+      panel, err := client.fetchComparedPanel(options, query, start, end)
+      if err != nil {
+        return panels, err
       }
-      counts = append(counts[len(newCounts):], newCounts...)
+      panels = append(panels, panel)
+      continue
+    }
 
-      renderErr := render(counts, metric, namespace, lookback, end)
-      if renderErr != nil {
-        return renderErr
+    var series Series
+    var err error
+    if batched != nil {
+      series = batched[i]
+    } else {
+      request := newMetricStatisticsRequest(query, start, end)
+      if series, err = client.getMetricSampleCounts(&request, options.fillPolicy()); err != nil {
+        return panels, err
+      }
+    }
+    query := query
+    panel := Panel{ Label: query.Label(), Series: series, Query: &query }
+    if len(options.compareStats) > 0 {
+      if panel, err = client.fetchComparedStat(options, panel, start, end); err != nil {
+        return panels, err
+      }
+    }
+    if options.band != "" {
+      if panel.Overlays, err = client.fetchBand(options, query, start, end); err != nil {
+        return panels, err
       }
     }
+    panels = append(panels, panel)
+  }
+  if options.compareMetrics {
+    return []Panel{ compareMetricsPanel(panels) }, nil
   }
 
-  return nil
+  return panels, nil
 }
 
-func (client Client) getMetricSampleCounts(request *cloudwatch.GetMetricStatisticsInput) (counts []float64, err error) {
-  datapoints, err := client.sendGetMetricStatisticsRequest(request)
+func (client Client) fetchDiffPanel(options Options, start time.Time, end time.Time) ([]Panel, error) {
+  minuend, subtrahend := options.queries[0], options.queries[1]
+  minuendRequest := newMetricStatisticsRequest(minuend, start, end)
+  minuendSeries, err := client.getMetricSampleCounts(&minuendRequest, options.fillPolicy())
+  if err != nil {
+    return []Panel{}, err
+  }
+  subtrahendRequest := newMetricStatisticsRequest(subtrahend, start, end)
+  subtrahendSeries, err := client.getMetricSampleCounts(&subtrahendRequest, options.fillPolicy())
   if err != nil {
-    return counts, err
+    return []Panel{}, err
+  }
+
+  diff, misaligned := diffSeries(minuendSeries, subtrahendSeries)
+  if misaligned > 0 {
+    warnf("Warning: %d bucket(s) of %s and %s don't line up in time and were left out of the difference", misaligned, minuend.Label(), subtrahend.Label())
+  }
+
+  return []Panel{ { Label: fmt.Sprintf("%s - %s", minuend.Label(), subtrahend.Label()), Series: diff } }, nil
+}
+
+func newMetricStatisticsRequest(query MetricQuery, start time.Time, end time.Time) cloudwatch.GetMetricStatisticsInput {
+  request := cloudwatch.GetMetricStatisticsInput{
+    MetricName: &query.Metric,
+    Namespace: &query.Namespace,
+    Dimensions: query.Dimensions,
+    StartTime: &start,
+    EndTime: &end,
+    Period: &query.Period,
+  }
+  setRequestStatistics(&request, query)
+
+  return request
+}
+
+func (client Client) getMetricSampleCounts(request *cloudwatch.GetMetricStatisticsInput, fill FillPolicy) (series Series, err error) {
+  if !request.StartTime.Before(*request.EndTime) {
+    return series, fmt.Errorf("%w: start %s is not before end %s", ErrInvalidWindow, request.StartTime, request.EndTime)
+  }
+  if client.variableResolution {
+    if segments := resolutionSegments(request); len(segments) > 1 {
+      return client.getVariableResolutionSampleCounts(segments, fill)
+    }
+  }
+
+  originalStart := *request.StartTime
+  trimmed, retained := trimToRetention(request, time.Now())
+  if !retained {
+    return series, fmt.Errorf("%w for %s/%s: CloudWatch no longer keeps %ds datapoints from before %s", ErrNoData, *request.Namespace, *request.MetricName, *request.Period, request.EndTime)
+  }
+  if trimmed {
+    warnf("Warning: %s/%s is only retained at a %ds period since %s, so the window was trimmed by %s", *request.Namespace, *request.MetricName, *request.Period, formatTime(*request.StartTime), request.StartTime.Sub(originalStart).Round(time.Second))
+  }
+
+  checkSamples := client.minSamples > 0 && requestSampleCounts(request)
+  // A series coarsened on an earlier fetch, e.g. the first of a tail, stays coarsened so it doesn't mix two periods
+  key := requestKey(request)
+  if client.coarsenedPeriods != nil {
+    if coarsened, ok := client.coarsenedPeriods.Load(key); ok {
+      request = copyGetMetricStatisticsInput(request)
+      request.Period = aws.Int64(coarsened.(int64))
+    }
+  }
+  datapoints, fetchedPeriod, err := client.sendGetMetricStatisticsRequest(request)
+  if err != nil {
+    return series, err
+  }
+  if fetchedPeriod != *request.Period && client.coarsenedPeriods != nil {
+    client.coarsenedPeriods.Store(key, fetchedPeriod)
+  }
+  period := time.Duration(fetchedPeriod) * time.Second
+  if client.info {
+    // Far fewer than expected usually means the metric is published less often than the period
+    expected := int(math.Ceil(request.EndTime.Sub(*request.StartTime).Seconds() / period.Seconds()))
+    infof("%s/%s: received %d of %d expected buckets", *request.Namespace, *request.MetricName, len(datapoints), expected)
+  }
+  if len(datapoints) == 0 {
+    return series, fmt.Errorf("%w for %s/%s between %s and %s", ErrNoData, *request.Namespace, *request.MetricName, request.StartTime, request.EndTime)
   }
 
   // Sort datapoints by timestamp
@@ -136,67 +1415,223 @@ func (client Client) getMetricSampleCounts(request *cloudwatch.GetMetricStatisti
     return datapoints[i].Timestamp.Unix() < datapoints[j].Timestamp.Unix()
   })
 
-  // Fill gaps w/ zeroes
-  var previousTime *time.Time = request.StartTime
-  for i := 0; i < len(datapoints); i++ {
-    currentDatapoint := datapoints[i]
-    numMinutesBetween := int(math.Round((currentDatapoint.Timestamp.Sub(*previousTime)).Minutes()))
-    for j := 0; j < numMinutesBetween; j++ {
-      counts = append(counts, 0)
+  points := make([]Point, len(datapoints))
+  for i, datapoint := range datapoints {
+    points[i] = Point{ Timestamp: *datapoint.Timestamp, Value: datapointValue(request, datapoint) }
+    if checkSamples && *datapoint.SampleCount < client.minSamples {
+      points[i].Value, points[i].Suppressed = math.NaN(), true
+    }
+  }
+  series = fillGaps(points, *request.StartTime, period, fill)
+  if fill.TrimEdges {
+    series = series.TrimFilled()
+  }
+
+  return series, nil
+}
+
+// Insert a bucket for each missing period between the sorted points, starting from the period containing start
+func fillGaps(points []Point, start time.Time, period time.Duration, fill FillPolicy) (series Series) {
+  expected := start.Truncate(period)
+  previous := math.NaN()
+  for _, point := range points {
+    numBucketsBetween := int(math.Round(point.Timestamp.Sub(expected).Seconds() / period.Seconds()))
+    fillValue := fill.valueFor(time.Duration(numBucketsBetween) * period, previous)
+    for j := 0; j < numBucketsBetween; j++ {
+      series = append(series, Point{ Timestamp: expected.Add(time.Duration(j) * period), Value: fillValue, Filled: true })
     }
-    counts = append(counts, *currentDatapoint.SampleCount)
-    previousTime = currentDatapoint.Timestamp
+    series = append(series, point)
+    expected = point.Timestamp.Add(period)
+    previous = point.Value
   }
 
-  return counts, nil
+  return series
 }
 
-func (client Client) sendGetMetricStatisticsRequest(request *cloudwatch.GetMetricStatisticsInput) ([]*cloudwatch.Datapoint, error) {
-  output, err := client.connection.GetMetricStatistics(request)
+// Fetch the request's datapoints, along with the period they're at: the request's own, unless -on-overflow coarsen had to fetch at a coarser one
+func (client Client) sendGetMetricStatisticsRequest(request *cloudwatch.GetMetricStatisticsInput) ([]*cloudwatch.Datapoint, int64, error) {
+  output, err := client.connection.GetMetricStatisticsWithContext(aws.BackgroundContext(), request, client.countFetchCalls)
   if err != nil {
-    if _, ok := err.(awserr.Error); ok {
-      return client.splitGetMetricStatisticsRequest(request, 2)
+    err = classifyAWSError(err)
+    if errors.Is(err, ErrAccessDenied) {
+      return []*cloudwatch.Datapoint{}, *request.Period, client.explainAccessDenied(err, "cloudwatch:GetMetricStatistics")
+    }
+    if client.onOverflow == overflowCoarsen && isTooManyDatapoints(err) {
+      window := request.EndTime.Sub(*request.StartTime)
+      coarser := coarserPeriod(*request.Period, window)
+      warnf("Warning: %s/%s has too many %ds datapoints for one request, so it's fetched at a %ds period instead", *request.Namespace, *request.MetricName, *request.Period, coarser)
+      // On a copy, since a split's sub-requests share the parent's fields and the parent still has to know which period each came back at
+      coarsened := copyGetMetricStatisticsInput(request)
+      coarsened.Period = &coarser
+      return client.sendGetMetricStatisticsRequest(coarsened)
     }
-    return []*cloudwatch.Datapoint{}, err
+    // Only split while each part still spans at least one period, otherwise we'd recurse forever on a persistent error
+    period := time.Duration(*request.Period) * time.Second
+    if isSplittable(err) && request.EndTime.Sub(*request.StartTime) >= time.Duration(client.parallelism) * period {
+      return client.splitGetMetricStatisticsRequest(request, client.parallelism)
+    }
+    return []*cloudwatch.Datapoint{}, *request.Period, err
+  }
+  // Unlike GetMetricData, GetMetricStatistics has no Messages to report; an empty response is all there is to go on
+  if client.dumpResponse {
+    dumpResponse(output)
   }
 
-  return output.Datapoints, nil
+  return output.Datapoints, *request.Period, nil
+}
+
+// Outcome of a single sub-request of a split
+type splitResult struct {
+  datapoints []*cloudwatch.Datapoint
+  // What the sub-request was fetched at, which -on-overflow coarsen may have raised
+  period int64
+  err error
+  // For the -info timing breakdown
+  start time.Time
+  end time.Time
+  latency time.Duration
 }
 
 // This will take a request and split it into n-many parallel requests to construct the output desired from the original request
-func (client Client) splitGetMetricStatisticsRequest(request *cloudwatch.GetMetricStatisticsInput, parallelism int) ([]*cloudwatch.Datapoint, error) {
+func (client Client) splitGetMetricStatisticsRequest(request *cloudwatch.GetMetricStatisticsInput, parallelism int) ([]*cloudwatch.Datapoint, int64, error) {
   fullStep := request.EndTime.Sub(*request.StartTime)
-  splitStep, err := time.ParseDuration(fmt.Sprintf("%dm", int(math.Round(fullStep.Minutes())) / parallelism))
-  if err != nil {
-    return []*cloudwatch.Datapoint{}, err
-  }
+  splitStep := fullStep / time.Duration(parallelism)
 
-  splitRequests := make(chan []*cloudwatch.Datapoint)
-  splitter := func (request cloudwatch.GetMetricStatisticsInput, start time.Time, end time.Time) {
+  splitRequests := make(chan splitResult)
+  splitter := func (request *cloudwatch.GetMetricStatisticsInput, start time.Time, end time.Time) {
     request.StartTime = &start
     request.EndTime = &end
-    counts, err := client.sendGetMetricStatisticsRequest(&request)
-    if err != nil {
-      return
-    }
-
-    splitRequests <- counts
+    sent := time.Now()
+    counts, period, err := client.sendGetMetricStatisticsRequest(request)
+    splitRequests <- splitResult{ datapoints: counts, period: period, err: err, start: start, end: end, latency: time.Since(sent) }
   }
 
   currentStepStart := *request.StartTime
   for i := 0; i < parallelism; i++ {
     splitStart := currentStepStart
     splitEnd := currentStepStart.Add(splitStep)
+    // The last sub-request takes whatever the division left over, so the whole window is fetched
+    if i == parallelism - 1 {
+      splitEnd = *request.EndTime
+    }
 
-    go splitter(*request, splitStart, splitEnd)
+    go splitter(copyGetMetricStatisticsInput(request), splitStart, splitEnd)
     currentStepStart = splitEnd
   }
 
-  datapoints := []*cloudwatch.Datapoint{}
+  // Drain every result before returning so no splitter is left blocked on the channel
+  results := []splitResult{}
+  var firstErr error
+  period := *request.Period
   for i := 0; i < parallelism; i++ {
     requestResult := <-splitRequests
-    datapoints = append(datapoints, requestResult...)
+    if requestResult.err != nil && firstErr == nil {
+      firstErr = requestResult.err
+    }
+    if requestResult.period > period {
+      period = requestResult.period
+    }
+    results = append(results, requestResult)
+  }
+  if client.info {
+    printSplitTimings(request, results)
+  }
+  if firstErr != nil {
+    return []*cloudwatch.Datapoint{}, period, firstErr
+  }
+
+  // If only some parts had to be coarsened, refetch the rest at the same period, since one series can't mix two
+  datapoints := []*cloudwatch.Datapoint{}
+  for _, result := range results {
+    if result.period < period {
+      part := copyGetMetricStatisticsInput(request)
+      part.StartTime, part.EndTime, part.Period = &result.start, &result.end, aws.Int64(period)
+      refetched, refetchedPeriod, err := client.sendGetMetricStatisticsRequest(part)
+      if err != nil {
+        return []*cloudwatch.Datapoint{}, period, err
+      }
+      if refetchedPeriod != period {
+        return []*cloudwatch.Datapoint{}, period, fmt.Errorf("%s/%s came back at both %ds and %ds periods across the split", *request.Namespace, *request.MetricName, period, refetchedPeriod)
+      }
+      result.datapoints = refetched
+    }
+    datapoints = append(datapoints, result.datapoints...)
+  }
+
+  return datapoints, period, nil
+}
+
+// One line per sub-request of a split in window order, so a single slow range stands out from the rest
+func printSplitTimings(request *cloudwatch.GetMetricStatisticsInput, results []splitResult) {
+  sort.Slice(results, func (i, j int) bool {
+    return results[i].start.Before(results[j].start)
+  })
+  var slowest time.Duration
+  for _, result := range results {
+    if result.latency > slowest {
+      slowest = result.latency
+    }
+  }
+
+  infof("%s/%s: split into %d sub-requests", *request.Namespace, *request.MetricName, len(results))
+  for _, result := range results {
+    outcome := fmt.Sprintf("%d datapoints", len(result.datapoints))
+    if result.err != nil {
+      outcome = "failed: " + result.err.Error()
+    }
+    marker := ""
+    if result.latency == slowest && len(results) > 1 {
+      marker = "  (slowest)"
+    }
+    infof("  %s to %s  %8s  %s%s", formatTime(result.start), formatTime(result.end), result.latency.Round(time.Millisecond), outcome, marker)
+  }
+}
+
+// What a request fetches, leaving out its window, so each poll of the same series has the same key
+func requestKey(request *cloudwatch.GetMetricStatisticsInput) string {
+  key := []string{ aws.StringValue(request.Namespace), aws.StringValue(request.MetricName), aws.StringValue(request.Unit), fmt.Sprint(aws.Int64Value(request.Period)) }
+  for _, dimension := range request.Dimensions {
+    key = append(key, aws.StringValue(dimension.Name) + "=" + aws.StringValue(dimension.Value))
+  }
+  key = append(key, aws.StringValueSlice(request.Statistics)...)
+  key = append(key, aws.StringValueSlice(request.ExtendedStatistics)...)
+
+  return strings.Join(key, "\x00")
+}
+
+// A copy of the request sharing nothing with it, down to the slices and the strings they point to, so each sub-request of a split can be changed without racing the others
+func copyGetMetricStatisticsInput(request *cloudwatch.GetMetricStatisticsInput) *cloudwatch.GetMetricStatisticsInput {
+  copied := &cloudwatch.GetMetricStatisticsInput{
+    Namespace: copyString(request.Namespace),
+    MetricName: copyString(request.MetricName),
+    Unit: copyString(request.Unit),
+  }
+  if request.Period != nil {
+    copied.Period = aws.Int64(*request.Period)
+  }
+  if request.StartTime != nil {
+    copied.StartTime = aws.Time(*request.StartTime)
+  }
+  if request.EndTime != nil {
+    copied.EndTime = aws.Time(*request.EndTime)
+  }
+  for _, statistic := range request.Statistics {
+    copied.Statistics = append(copied.Statistics, copyString(statistic))
+  }
+  for _, statistic := range request.ExtendedStatistics {
+    copied.ExtendedStatistics = append(copied.ExtendedStatistics, copyString(statistic))
+  }
+  for _, dimension := range request.Dimensions {
+    copied.Dimensions = append(copied.Dimensions, &cloudwatch.Dimension{ Name: copyString(dimension.Name), Value: copyString(dimension.Value) })
+  }
+
+  return copied
+}
+
+func copyString(value *string) *string {
+  if value == nil {
+    return nil
   }
 
-  return datapoints, nil
+  return aws.String(*value)
 }
@@ -0,0 +1,298 @@
+package main
+
+import (
+  "errors"
+  "fmt"
+  "math"
+  "net/http"
+  "net/http/httptest"
+  "sort"
+  "strings"
+  "sync"
+  "testing"
+  "time"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/aws/credentials"
+  "github.com/aws/aws-sdk-go/aws/session"
+  "github.com/aws/aws-sdk-go/service/cloudwatch"
+)
+
+// A client whose CloudWatch calls go to handler instead of AWS
+func newTestClient(t *testing.T, handler http.HandlerFunc) Client {
+  server := httptest.NewServer(handler)
+  t.Cleanup(server.Close)
+
+  sess := session.Must(session.NewSession(&aws.Config{
+    Region: aws.String("us-east-1"),
+    Endpoint: aws.String(server.URL),
+    Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+    MaxRetries: aws.Int(0),
+  }))
+  var requestCount, dataRequestCount, fetchLatency int64
+  return Client{ connection: cloudwatch.New(sess), session: sess, parallelism: 1, onOverflow: overflowSplit, requestCount: &requestCount, dataRequestCount: &dataRequestCount, fetchLatency: &fetchLatency, coarsenedPeriods: &sync.Map{} }
+}
+
+// A GetMetricStatistics response with a datapoint of the given sample count at each timestamp
+func metricStatisticsResponse(writer http.ResponseWriter, timestamps []time.Time, value float64) {
+  fmt.Fprint(writer, `<GetMetricStatisticsResponse xmlns="http://monitoring.amazonaws.com/doc/2010-08-01/"><GetMetricStatisticsResult><Label>test</Label><Datapoints>`)
+  for _, timestamp := range timestamps {
+    fmt.Fprintf(writer, `<member><Timestamp>%s</Timestamp><SampleCount>%g</SampleCount><Sum>%g</Sum><Unit>Count</Unit></member>`, timestamp.UTC().Format(time.RFC3339), value, value)
+  }
+  fmt.Fprint(writer, `</Datapoints></GetMetricStatisticsResult></GetMetricStatisticsResponse>`)
+}
+
+// An error response in the query protocol's format
+func errorResponse(writer http.ResponseWriter, status int, code string, message string) {
+  writer.WriteHeader(status)
+  fmt.Fprintf(writer, `<ErrorResponse><Error><Type>Sender</Type><Code>%s</Code><Message>%s</Message></Error><RequestId>test</RequestId></ErrorResponse>`, code, message)
+}
+
+func TestSplitGetMetricStatisticsRequest(t *testing.T) {
+  cases := []struct {
+    name string
+    window time.Duration
+    parallelism int
+  }{
+    { name: "uneven minutes", window: 10 * time.Minute + 30 * time.Second, parallelism: 4 },
+    { name: "under a minute per part", window: 2 * time.Minute, parallelism: 8 },
+    { name: "long window", window: 7 * 24 * time.Hour, parallelism: 7 },
+  }
+
+  for _, c := range cases {
+    t.Run(c.name, func (t *testing.T) {
+      var mutex sync.Mutex
+      ranges := [][2]time.Time{}
+      client := newTestClient(t, func (writer http.ResponseWriter, request *http.Request) {
+        request.ParseForm()
+        start, _ := time.Parse(time.RFC3339, request.PostForm.Get("StartTime"))
+        end, _ := time.Parse(time.RFC3339, request.PostForm.Get("EndTime"))
+        mutex.Lock()
+        ranges = append(ranges, [2]time.Time{ start, end })
+        mutex.Unlock()
+        metricStatisticsResponse(writer, []time.Time{ start }, 1)
+      })
+
+      start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+      end := start.Add(c.window)
+      request := cloudwatch.GetMetricStatisticsInput{
+        Namespace: aws.String("Test"),
+        MetricName: aws.String("Metric"),
+        Period: aws.Int64(1),
+        Statistics: []*string{ aws.String("SampleCount") },
+        StartTime: &start,
+        EndTime: &end,
+      }
+      datapoints, _, err := client.splitGetMetricStatisticsRequest(&request, c.parallelism)
+      if err != nil {
+        t.Fatal(err)
+      }
+      if len(datapoints) != c.parallelism {
+        t.Errorf("got %d datapoints, want one from each of %d sub-requests", len(datapoints), c.parallelism)
+      }
+
+      sort.Slice(ranges, func (i, j int) bool {
+        return ranges[i][0].Before(ranges[j][0])
+      })
+      if len(ranges) != c.parallelism {
+        t.Fatalf("sent %d sub-requests, want %d", len(ranges), c.parallelism)
+      }
+      expected := start
+      for _, sent := range ranges {
+        if !sent[0].Equal(expected) {
+          t.Errorf("sub-request starts at %s, want %s", sent[0], expected)
+        }
+        if !sent[1].After(sent[0]) {
+          t.Errorf("sub-request %s to %s is empty", sent[0], sent[1])
+        }
+        expected = sent[1]
+      }
+      if !expected.Equal(end) {
+        t.Errorf("sub-requests end at %s, want the window's end %s", expected, end)
+      }
+    })
+  }
+}
+
+func TestRenderRejectsLookbackShorterThanPeriod(t *testing.T) {
+  client := newTestClient(t, func (writer http.ResponseWriter, request *http.Request) {
+    t.Errorf("fetched %s although no bucket fits in the window", request.URL)
+  })
+  options := Options{ period: 300, lookback: -time.Minute, queries: []MetricQuery{{ Namespace: "Test", Metric: "Metric", Period: 300, Stat: "Sum" }} }
+
+  err := client.renderMetricSampleCounts(options)
+  if !errors.Is(err, ErrInvalidWindow) {
+    t.Fatalf("got %v, want ErrInvalidWindow", err)
+  }
+  if exitCode(err) != exitInvalidWindow {
+    t.Errorf("exits %d, want %d", exitCode(err), exitInvalidWindow)
+  }
+}
+
+func TestZeroAlignedBoundsPutsZeroOnARow(t *testing.T) {
+  start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+  for _, values := range [][]float64{ { -3, 7, 2, -1 }, { -0.4, 13.7 }, { -250, 3 }, { -1, 1 } } {
+    series := Series{}
+    for i, value := range values {
+      series = append(series, Point{ Timestamp: start.Add(time.Duration(i) * time.Minute), Value: value })
+    }
+    for _, height := range []int{ 2, 7, 10, 23 } {
+      lower, upper, ok := zeroAlignedBounds(series, height)
+      if !ok {
+        t.Fatalf("%v at height %d: not aligned, though it crosses zero", values, height)
+      }
+      min, max, _ := series.Bounds()
+      if lower > min || upper < max {
+        t.Errorf("%v at height %d: bounds %g to %g cut off the data", values, height, lower, upper)
+      }
+      // Row r from the top is labelled upper - r * (upper - lower) / height, so zero is on a row when r comes out whole
+      row := upper * float64(height) / (upper - lower)
+      if math.Abs(row - math.Round(row)) > 1e-9 || row < 0 || row > float64(height) {
+        t.Errorf("%v at height %d: zero falls at row %g, between rows", values, height, row)
+      }
+    }
+  }
+
+  if _, _, ok := zeroAlignedBounds(Series{{ Timestamp: start, Value: 1 }, { Timestamp: start.Add(time.Minute), Value: 5 }}, 10); ok {
+    t.Errorf("aligned a series that never crosses zero")
+  }
+}
+
+func TestPlotPanelsDegenerateSeries(t *testing.T) {
+  start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+  cases := map[string]Series{
+    "single point": {{ Timestamp: start, Value: 42 }},
+    "all equal": {{ Timestamp: start, Value: 3 }, { Timestamp: start.Add(time.Minute), Value: 3 }, { Timestamp: start.Add(2 * time.Minute), Value: 3 }},
+    "all zero": {{ Timestamp: start, Value: 0 }, { Timestamp: start.Add(time.Minute), Value: 0 }},
+  }
+
+  for name, series := range cases {
+    t.Run(name, func (t *testing.T) {
+      panels := []Panel{{ Label: "metric", Series: series }}
+      end := start.Add(time.Hour)
+      func () {
+        defer func () {
+          if recovered := recover(); recovered != nil {
+            t.Errorf("plotting panicked: %v", recovered)
+          }
+        }()
+        plotPanels(panels, "Test", -time.Hour, end, 80, 20)
+      }()
+      if graph := safePlotPanels(panels, "Test", -time.Hour, end, 80, 20); strings.TrimSpace(graph) == "" {
+        t.Errorf("drew nothing")
+      }
+    })
+  }
+}
+
+func TestCoarsenedSplitKeepsOnePeriod(t *testing.T) {
+  end := time.Now().UTC().Truncate(time.Hour)
+  start := end.Add(-2000 * time.Minute)
+  var mutex sync.Mutex
+  periods := []int64{}
+  client := newTestClient(t, func (writer http.ResponseWriter, request *http.Request) {
+    request.ParseForm()
+    from, _ := time.Parse(time.RFC3339, request.PostForm.Get("StartTime"))
+    to, _ := time.Parse(time.RFC3339, request.PostForm.Get("EndTime"))
+    var period int64
+    fmt.Sscan(request.PostForm.Get("Period"), &period)
+    mutex.Lock()
+    periods = append(periods, period)
+    mutex.Unlock()
+
+    switch {
+    // The whole window fails in a way splitting can help with
+    case from.Equal(start) && to.Equal(end):
+      errorResponse(writer, http.StatusInternalServerError, "InternalFailure", "try a smaller request")
+    // Only the first half has too much data at the fine period, so only it gets coarsened
+    case from.Equal(start) && period == 60:
+      errorResponse(writer, http.StatusBadRequest, "InvalidParameterCombination", "You have requested up to 2,000 datapoints, which exceeds the limit of 1,440 datapoints")
+    default:
+      timestamps := []time.Time{}
+      for timestamp := from; timestamp.Before(to); timestamp = timestamp.Add(time.Duration(period) * time.Second) {
+        timestamps = append(timestamps, timestamp)
+      }
+      metricStatisticsResponse(writer, timestamps, 1)
+    }
+  })
+  client.onOverflow, client.parallelism = overflowCoarsen, 2
+
+  query := MetricQuery{ Namespace: "Test", Metric: "Metric", Period: 60, Stat: "Sum" }
+  request := newMetricStatisticsRequest(query, start, end)
+  series, err := client.getMetricSampleCounts(&request, FillPolicy{ Strategy: fillZero })
+  if err != nil {
+    t.Fatal(err)
+  }
+  step := series[1].Timestamp.Sub(series[0].Timestamp)
+  if step == time.Minute {
+    t.Fatalf("series is at the original 60s period, want the coarsened one")
+  }
+  for i, point := range series {
+    if point.Filled {
+      t.Errorf("bucket at %s was gap-filled, though every bucket came back", point.Timestamp)
+    }
+    if i > 0 && point.Timestamp.Sub(series[i - 1].Timestamp) != step {
+      t.Errorf("buckets at %s and %s are %s apart, want every bucket %s apart", series[i - 1].Timestamp, point.Timestamp, point.Timestamp.Sub(series[i - 1].Timestamp), step)
+    }
+  }
+
+  // A later poll, e.g. while tailing, stays at the coarsened period even though its short window would fit at the fine one
+  mutex.Lock()
+  periods = nil
+  mutex.Unlock()
+  poll := newMetricStatisticsRequest(query, end.Add(-10 * time.Minute), end)
+  if _, err := client.getMetricSampleCounts(&poll, FillPolicy{ Strategy: fillZero }); err != nil {
+    t.Fatal(err)
+  }
+  if len(periods) != 1 || time.Duration(periods[0]) * time.Second != step {
+    t.Errorf("poll fetched at periods %v, want only the coarsened %s", periods, step)
+  }
+}
+
+func TestRenderEmptyWindowFailsOnlyWithFailOnEmpty(t *testing.T) {
+  client := newTestClient(t, func (writer http.ResponseWriter, request *http.Request) {
+    metricStatisticsResponse(writer, nil, 0)
+  })
+  options := Options{ period: 60, lookback: -time.Hour, queries: []MetricQuery{{ Namespace: "Test", Metric: "Metric", Period: 60, Stat: "Sum" }} }
+
+  if err := client.renderMetricSampleCounts(options); err != nil {
+    t.Errorf("got %v for an empty window without -fail-on-empty, want only a warning", err)
+  }
+
+  options.failOnEmpty = true
+  if err := client.renderMetricSampleCounts(options); exitCode(err) != exitNoData {
+    t.Errorf("got %v exiting %d with -fail-on-empty, want %d", err, exitCode(err), exitNoData)
+  }
+}
+
+func TestFetchCallsCountOnlyTheirOwnFetch(t *testing.T) {
+  client := newTestClient(t, func (writer http.ResponseWriter, request *http.Request) {
+    request.ParseForm()
+    start, _ := time.Parse(time.RFC3339, request.PostForm.Get("StartTime"))
+    metricStatisticsResponse(writer, []time.Time{ start }, 1)
+  })
+  end := time.Now().UTC().Truncate(time.Minute)
+  query := MetricQuery{ Namespace: "Test", Metric: "Metric", Period: 60, Stat: "Sum" }
+
+  // Another fetch on a copy of the client, like a tail feed, runs alongside the counted one
+  var wg sync.WaitGroup
+  wg.Add(1)
+  go func () {
+    defer wg.Done()
+    for i := 0; i < 5; i++ {
+      request := newMetricStatisticsRequest(query, end.Add(-time.Hour), end)
+      client.getMetricSampleCounts(&request, FillPolicy{ Strategy: fillZero })
+    }
+  }()
+  counted := client
+  counted.fetchCalls = &fetchCalls{}
+  request := newMetricStatisticsRequest(query, end.Add(-time.Hour), end)
+  if _, err := counted.getMetricSampleCounts(&request, FillPolicy{ Strategy: fillZero }); err != nil {
+    t.Fatal(err)
+  }
+  wg.Wait()
+
+  if counted.fetchCalls.statistics != 1 || counted.fetchCalls.data != 0 {
+    t.Errorf("counted %d GetMetricStatistics and %d GetMetricData call(s), want only the fetch's own 1", counted.fetchCalls.statistics, counted.fetchCalls.data)
+  }
+}
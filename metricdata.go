@@ -0,0 +1,227 @@
+package main
+
+import (
+  "fmt"
+  "sort"
+  "time"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/cloudwatch"
+)
+
+// Values accepted by -api
+const (
+  apiAuto = "auto"
+  apiStatistics = "statistics"
+  apiMetricData = "data"
+)
+
+// Id given to the nth query in GetMetricData requests, and so the name -expression refers to it by
+func metricID(index int) string {
+  return fmt.Sprintf("m%d", index + 1)
+}
+
+// Id given to the FILL expression over the nth query, whose result fills that query's gaps
+func filledID(index int) string {
+  return fmt.Sprintf("filled%d", index + 1)
+}
+
+// The metric math filling the series with the given id as -fill does, so gaps are filled exactly as the console fills them.
+// Empty when it's left to fillGaps: -fill none leaves gaps, and FILL can't spare the long ones -max-gap would.
+func (fill FillPolicy) expression(id string) string {
+  if fill.MaxGap > 0 {
+    return ""
+  }
+  switch fill.Strategy {
+  case fillZero:
+    return "FILL(" + id + ", 0)"
+  case fillRepeat:
+    return "FILL(" + id + ", REPEAT)"
+  }
+
+  return ""
+}
+
+// The datapoints with the buckets only the FILL result has added, marked as filled. Filled buckets before start are dropped, since the caller doesn't want that stretch filled.
+func mergeFilled(points []Point, filled []Point, start time.Time) []Point {
+  actual := map[int64]bool{}
+  for _, point := range points {
+    actual[point.Timestamp.Unix()] = true
+  }
+  merged := append([]Point{}, points...)
+  for _, point := range filled {
+    if !actual[point.Timestamp.Unix()] && !point.Timestamp.Before(start) {
+      merged = append(merged, Point{ Timestamp: point.Timestamp, Value: point.Value, Filled: true })
+    }
+  }
+  sort.Slice(merged, func (a, b int) bool {
+    return merged[a].Timestamp.Before(merged[b].Timestamp)
+  })
+
+  return merged
+}
+
+// Evaluate -expression over the queries with GetMetricData. Each query is available to the expression as m1, m2, ... in -metric order.
+func (client Client) getExpressionSeries(options Options, start time.Time, end time.Time) (Series, error) {
+  if !start.Before(end) {
+    return Series{}, fmt.Errorf("%w: start %s is not before end %s", ErrInvalidWindow, start, end)
+  }
+
+  dataQueries := []*cloudwatch.MetricDataQuery{}
+  for i, query := range options.queries {
+    query := query
+    dataQueries = append(dataQueries, &cloudwatch.MetricDataQuery{
+      Id: aws.String(metricID(i)),
+      MetricStat: &cloudwatch.MetricStat{
+        Metric: &cloudwatch.Metric{ MetricName: &query.Metric, Namespace: &query.Namespace, Dimensions: query.Dimensions },
+        Period: &query.Period,
+        Stat: &query.Stat,
+      },
+      ReturnData: aws.Bool(false),
+    })
+  }
+  dataQueries = append(dataQueries, &cloudwatch.MetricDataQuery{
+    Id: aws.String("expression"),
+    Expression: &options.expression,
+    ReturnData: aws.Bool(true),
+  })
+  fill := options.fillPolicy()
+  if expression := fill.expression("expression"); expression != "" {
+    dataQueries = append(dataQueries, &cloudwatch.MetricDataQuery{ Id: aws.String("filled"), Expression: &expression, ReturnData: aws.Bool(true) })
+  }
+
+  input := cloudwatch.GetMetricDataInput{
+    MetricDataQueries: dataQueries,
+    StartTime: &start,
+    EndTime: &end,
+    ScanBy: aws.String(cloudwatch.ScanByTimestampAscending),
+  }
+  points, filled := []Point{}, []Point{}
+  err := client.connection.GetMetricDataPagesWithContext(aws.BackgroundContext(), &input, func (page *cloudwatch.GetMetricDataOutput, lastPage bool) bool {
+    reportMessages(page)
+    for _, result := range page.MetricDataResults {
+      for i := range result.Timestamps {
+        point := Point{ Timestamp: *result.Timestamps[i], Value: *result.Values[i] }
+        if aws.StringValue(result.Id) == "filled" {
+          filled = append(filled, point)
+        } else {
+          points = append(points, point)
+        }
+      }
+    }
+    return true
+  }, client.countFetchCalls)
+  if err != nil {
+    return Series{}, classifyAWSError(err)
+  }
+  if len(points) == 0 {
+    return Series{}, fmt.Errorf("%w for expression %s between %s and %s", ErrNoData, options.expression, start, end)
+  }
+
+  return fillGaps(mergeFilled(points, filled, start), start, time.Duration(options.period) * time.Second, fill), nil
+}
+
+// Print the warnings CloudWatch attaches to a response, e.g. that a result was truncated or the expression hit an error. They often explain an empty or partial graph.
+func reportMessages(page *cloudwatch.GetMetricDataOutput) {
+  for _, message := range page.Messages {
+    warnf("CloudWatch: %s: %s", aws.StringValue(message.Code), aws.StringValue(message.Value))
+  }
+  for _, result := range page.MetricDataResults {
+    for _, message := range result.Messages {
+      warnf("CloudWatch: %s: %s: %s", aws.StringValue(result.Label), aws.StringValue(message.Code), aws.StringValue(message.Value))
+    }
+  }
+}
+
+// Most queries GetMetricData accepts in a single request
+const maxMetricDataQueries = 500
+
+// Fetch every query's series with as few GetMetricData requests as possible, rather than a GetMetricStatistics call each. Series are in query order.
+func (client Client) getMetricDataSeries(queries []MetricQuery, start time.Time, end time.Time, fill FillPolicy) ([]Series, error) {
+  if !start.Before(end) {
+    return nil, fmt.Errorf("%w: start %s is not before end %s", ErrInvalidWindow, start, end)
+  }
+
+  points, filled := make([][]Point, len(queries)), make([][]Point, len(queries))
+  // Each query takes two slots when CloudWatch fills it
+  batchSize := maxMetricDataQueries
+  if fill.expression(metricID(0)) != "" {
+    batchSize /= 2
+  }
+  for batchStart := 0; batchStart < len(queries); batchStart += batchSize {
+    batchEnd := batchStart + batchSize
+    if batchEnd > len(queries) {
+      batchEnd = len(queries)
+    }
+
+    dataQueries := []*cloudwatch.MetricDataQuery{}
+    for i := batchStart; i < batchEnd; i++ {
+      query := queries[i]
+      dataQueries = append(dataQueries, &cloudwatch.MetricDataQuery{
+        Id: aws.String(metricID(i)),
+        MetricStat: &cloudwatch.MetricStat{
+          Metric: &cloudwatch.Metric{ MetricName: &query.Metric, Namespace: &query.Namespace, Dimensions: query.Dimensions },
+          Period: &query.Period,
+          Stat: &query.Stat,
+        },
+        ReturnData: aws.Bool(true),
+      })
+      if expression := fill.expression(metricID(i)); expression != "" {
+        dataQueries = append(dataQueries, &cloudwatch.MetricDataQuery{ Id: aws.String(filledID(i)), Expression: aws.String(expression), ReturnData: aws.Bool(true) })
+      }
+    }
+
+    input := cloudwatch.GetMetricDataInput{
+      MetricDataQueries: dataQueries,
+      StartTime: &start,
+      EndTime: &end,
+      ScanBy: aws.String(cloudwatch.ScanByTimestampAscending),
+    }
+    err := client.connection.GetMetricDataPagesWithContext(aws.BackgroundContext(), &input, func (page *cloudwatch.GetMetricDataOutput, lastPage bool) bool {
+      if client.dumpResponse {
+        dumpResponse(page)
+      }
+      reportMessages(page)
+      for _, result := range page.MetricDataResults {
+        var index int
+        target := points
+        if _, err := fmt.Sscanf(aws.StringValue(result.Id), "filled%d", &index); err == nil {
+          target = filled
+        } else if _, err := fmt.Sscanf(aws.StringValue(result.Id), "m%d", &index); err != nil {
+          continue
+        }
+        if index < 1 || index > len(queries) {
+          continue
+        }
+        for i := range result.Timestamps {
+          target[index - 1] = append(target[index - 1], Point{ Timestamp: *result.Timestamps[i], Value: *result.Values[i] })
+        }
+      }
+      return true
+    }, client.countFetchCalls)
+    if err != nil {
+      return nil, classifyAWSError(err)
+    }
+  }
+
+  series := make([]Series, len(queries))
+  now := time.Now()
+  for i, query := range queries {
+    if len(points[i]) == 0 {
+      return nil, fmt.Errorf("%w for %s/%s between %s and %s", ErrNoData, query.Namespace, query.Metric, start, end)
+    }
+    // Don't gap-fill the stretch CloudWatch no longer retains at this period
+    fillStart := start
+    if boundary := retentionBoundary(query.Period, now); fillStart.Before(boundary) {
+      warnf("Warning: %s/%s is only retained at a %ds period since %s, so the window was trimmed", query.Namespace, query.Metric, query.Period, formatTime(boundary))
+      fillStart = boundary
+    }
+    // Each page is in time order, but a query's datapoints can be spread across pages, which merging sorts out
+    series[i] = fillGaps(mergeFilled(points[i], filled[i], fillStart), fillStart, time.Duration(query.Period) * time.Second, fill)
+    if fill.TrimEdges {
+      series[i] = series[i].TrimFilled()
+    }
+  }
+
+  return series, nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+  "fmt"
+  "strings"
+)
+
+// The flags that each pick what cw-top does, in the order main dispatches on them. Only the first given would run, so any two of them conflict.
+var dispatchModes = []string{ "-from-file", "-doctor", "-describe", "-probe", "-regression-threshold", "-detect-flatline", "-alert-above", "-archive", "-top", "-grid", "-compare-regions", "-log-group", "-serve-graph", "-snapshot", "-benchmark", "-output", "-socket", "-multi-lookback", "-window-step" }
+
+// A flag and the other flags it can't be combined with, besides the dispatch modes it already conflicts with. Flags are named as they appear in the error.
+type exclusiveMode struct {
+  flag string
+  // Why the flag rules the others out, when it isn't obvious
+  reason string
+  conflicts []string
+}
+
+var exclusiveModes = []exclusiveMode{
+  { flag: "-from-file", reason: "only draws what's saved", conflicts: []string{ "-tail", "-diff", "-expression", "-compare", "-dashboard", "-stack" } },
+  { flag: "-describe", conflicts: []string{ "-tail" } },
+  { flag: "-probe", reason: "checks a single series", conflicts: []string{ "multiple -metric", "-group-by", "-stack", "-tail", "-dashboard", "-compare-namespaces" } },
+  { flag: "-archive", reason: "only draws the archived -metric", conflicts: []string{ "-tail", "-diff", "-expression", "-compare", "-derive", "-dashboard", "-stack", "-group-by" } },
+  { flag: "-top", conflicts: []string{ "-diff", "-stacked", "-stack", "-compare" } },
+  { flag: "-grid", conflicts: []string{ "-diff", "-expression", "-compare", "-band", "-compare-metrics", "-dashboard", "-on-update" } },
+  { flag: "-log-group", conflicts: []string{ "-diff", "-stacked", "-stack" } },
+  { flag: "-serve-graph", reason: "draws a graph per HTTP request", conflicts: []string{ "-tail" } },
+  { flag: "-snapshot", conflicts: []string{ "-tail", "-dashboard" } },
+  { flag: "-benchmark", reason: "times repeated fetches", conflicts: []string{ "-tail" } },
+  { flag: "-multi-lookback", conflicts: []string{ "-dashboard", "-on-update", "-display-period" } },
+  { flag: "-window-step", conflicts: []string{ "-tail" } },
+  { flag: "-clipboard", reason: "copies the output once it's complete", conflicts: []string{ "-tail", "-alert-above", "-serve-graph", "-socket", "-window-step" } },
+}
+
+// Which of the flags named in dispatchModes and exclusiveModes the options use, once they're all parsed
+func (options Options) modeFlags() map[string]bool {
+  return map[string]bool{
+    "multiple -metric": len(options.metrics) > 1,
+    "-group-by": options.groupBy != "",
+    "-stack": options.stack != "",
+    "-stacked": options.stacked,
+    "-diff": options.diff,
+    "-expression": options.expression != "",
+    "-compare": options.compare > 0,
+    "-compare-metrics": options.compareMetrics,
+    "-derive": options.derive != "",
+    "-band": options.band != "",
+    "-tail": options.tail,
+    "-output": options.output != "graph",
+    "-top": options.top,
+    "-grid": options.grid,
+    "-dashboard": options.dashboard != "",
+    "-log-group": options.logGroup != "",
+    "-archive": options.archive != "",
+    "-from-file": options.fromFile != "",
+    "-doctor": options.doctor,
+    "-compare-regions": len(options.compareRegions) > 0,
+    "-compare-namespaces": len(options.compareNamespaces) > 0,
+    "-alert-above": options.alertAbove.set,
+    "-serve-graph": options.serveGraph != "",
+    "-regression-threshold": options.regressionThreshold.set,
+    "-detect-flatline": options.detectFlatline > 0,
+    "-socket": options.socket != "",
+    "-on-update": options.onUpdate != "",
+    "-display-period": options.displayPeriod > 0,
+    "-describe": options.describe,
+    "-benchmark": options.benchmark > 0,
+    "-multi-lookback": len(options.multiLookback) > 0,
+    "-snapshot": options.snapshot != "",
+    "-probe": options.probe,
+    "-window-step": options.windowStep > 0,
+    "-clipboard": options.clipboard,
+  }
+}
+
+// Fail if two dispatch modes are used together, or a flag alongside one it can't be combined with, naming everything it can't be combined with
+func checkExclusiveModes(used map[string]bool) error {
+  given := ""
+  for _, mode := range dispatchModes {
+    if !used[mode] {
+      continue
+    }
+    if given != "" {
+      return fmt.Errorf("%s and %s each pick what cw-top does, so give only one", given, mode)
+    }
+    given = mode
+  }
+
+  for _, mode := range exclusiveModes {
+    if !used[mode.flag] {
+      continue
+    }
+    for _, conflict := range mode.conflicts {
+      if !used[conflict] {
+        continue
+      }
+      if mode.reason != "" {
+        return fmt.Errorf("%s %s, so it can't be combined with %s", mode.flag, mode.reason, joinOr(mode.conflicts))
+      }
+      return fmt.Errorf("%s can't be combined with %s", mode.flag, joinOr(mode.conflicts))
+    }
+  }
+
+  return nil
+}
+
+// e.g. "-a, -b, or -c"
+func joinOr(items []string) string {
+  if len(items) < 3 {
+    return strings.Join(items, " or ")
+  }
+
+  return strings.Join(items[:len(items) - 1], ", ") + ", or " + items[len(items) - 1]
+}
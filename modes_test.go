@@ -0,0 +1,59 @@
+package main
+
+import (
+  "strings"
+  "testing"
+)
+
+func TestCheckExclusiveModes(t *testing.T) {
+  cases := []struct {
+    used []string
+    wantErr string
+  }{
+    { used: []string{ "-tail", "-socket" } },
+    { used: []string{ "-tail", "-output" } },
+    { used: []string{ "-probe" } },
+    { used: []string{ "-clipboard", "-output" } },
+    { used: []string{ "-clipboard", "-tail" }, wantErr: "-clipboard copies the output once it's complete, so it can't be combined with -tail," },
+    { used: []string{ "-probe", "multiple -metric" }, wantErr: "-probe checks a single series, so it can't be combined with multiple -metric," },
+    { used: []string{ "-window-step", "-probe" }, wantErr: "-probe and -window-step each pick what cw-top does" },
+    { used: []string{ "-top", "-serve-graph" }, wantErr: "-top and -serve-graph each pick what cw-top does" },
+    { used: []string{ "-top", "-log-group" }, wantErr: "-top and -log-group each pick what cw-top does" },
+    { used: []string{ "-describe", "-top" }, wantErr: "-describe and -top each pick what cw-top does" },
+    { used: []string{ "-benchmark", "-top" }, wantErr: "-top and -benchmark each pick what cw-top does" },
+    { used: []string{ "-serve-graph", "-output" }, wantErr: "-serve-graph and -output each pick what cw-top does" },
+    { used: []string{ "-describe", "-tail" }, wantErr: "-describe can't be combined with -tail" },
+    { used: []string{ "-top", "-tail" } },
+    { used: []string{ "-top", "-stacked" }, wantErr: "-top can't be combined with -diff," },
+    { used: []string{ "-multi-lookback", "-display-period" }, wantErr: "-multi-lookback can't be combined with" },
+  }
+
+  for _, c := range cases {
+    used := map[string]bool{}
+    for _, flag := range c.used {
+      used[flag] = true
+    }
+    err := checkExclusiveModes(used)
+    switch {
+    case c.wantErr == "" && err != nil:
+      t.Errorf("%v: got %v, want no error", c.used, err)
+    case c.wantErr != "" && (err == nil || !strings.HasPrefix(err.Error(), c.wantErr)):
+      t.Errorf("%v: got %v, want an error starting %q", c.used, err, c.wantErr)
+    }
+  }
+
+  // Every flag the table names has to be one modeFlags reports, or its conflicts would never be caught
+  known := Options{}.modeFlags()
+  for _, mode := range dispatchModes {
+    if _, ok := known[mode]; !ok {
+      t.Errorf("dispatch mode %s isn't one modeFlags reports", mode)
+    }
+  }
+  for _, mode := range exclusiveModes {
+    for _, flag := range append([]string{ mode.flag }, mode.conflicts...) {
+      if _, ok := known[flag]; !ok {
+        t.Errorf("%s names %s, which modeFlags doesn't report", mode.flag, flag)
+      }
+    }
+  }
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+  "os"
+  "time"
+)
+
+// Draw the queries over each -multi-lookback window in turn, stacked, with each caption naming its window, for e.g. the last hour in detail above the last day for context.
+// While tailing, each window is refetched as often as its period gets a new bucket, so a day at 60s isn't fetched more often than an hour at the same period would be.
+func (client Client) renderMultiLookback(options Options) error {
+  resized := make(chan os.Signal, 1)
+  notifyOnResize(resized)
+
+  windows := make([][]Panel, len(options.multiLookback))
+  fetched := make([]time.Time, len(options.multiLookback))
+  for {
+    end := time.Now()
+    next := time.Duration(0)
+    for i, window := range options.multiLookback {
+      interval := tailInterval(time.Duration(options.multiLookbackPeriods[i]) * time.Second)
+      if windows[i] == nil || end.Sub(fetched[i]) >= interval {
+        panels, err := client.fetchPanels(options.forWindow(i), end.Add(-window), end)
+        if err != nil {
+          return err
+        }
+        for j := range panels {
+          panels[j].Lookback = -window
+        }
+        windows[i], fetched[i] = panels, end
+      }
+      if wait := interval - end.Sub(fetched[i]); next == 0 || wait < next {
+        next = wait
+      }
+    }
+
+    panels := []Panel{}
+    for _, window := range windows {
+      panels = append(panels, window...)
+    }
+    if err := render(transformPanels(options, panels, end), options.namespace, options.lookback, end); err != nil {
+      return err
+    }
+    if !options.tail {
+      return nil
+    }
+
+    // Sleep until the soonest window is due, but redraw them all from the cache at once on a resize
+    select {
+    case <-resized:
+    case <-time.After(next):
+    }
+  }
+}
+
+// The options for fetching the i-th -multi-lookback window at its own period
+func (options Options) forWindow(i int) Options {
+  options.lookback = -options.multiLookback[i]
+  options.period = options.multiLookbackPeriods[i]
+  queries := make([]MetricQuery, len(options.queries))
+  for j, query := range options.queries {
+    query.Period = options.period
+    queries[j] = query
+  }
+  options.queries = queries
+
+  return options
+}
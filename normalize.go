@@ -0,0 +1,63 @@
+package main
+
+import (
+  "math"
+)
+
+// Values accepted by -normalize, with how the caption describes each
+var normalizations = map[string]string{
+  "maxpct": "% of max",
+  "zscore": "z-score",
+}
+
+// Rescale the panel's series and each overlay independently, so series of very different magnitudes can be compared by shape on one axis
+func normalizePanel(panel Panel, mode string) Panel {
+  panel.Series = panel.Series.Normalize(mode)
+  panel.Normalized = mode
+  overlays := make([]Panel, len(panel.Overlays))
+  for i, overlay := range panel.Overlays {
+    overlays[i] = normalizePanel(overlay, mode)
+  }
+  panel.Overlays = overlays
+
+  return panel
+}
+
+// The series as a percentage of its largest magnitude (maxpct), or as standard deviations from its mean (zscore). Breaks stay breaks, and a flat series is left alone rather than divided by zero.
+func (series Series) Normalize(mode string) Series {
+  values := []float64{}
+  for _, point := range series {
+    if !math.IsNaN(point.Value) {
+      values = append(values, point.Value)
+    }
+  }
+  if len(values) == 0 {
+    return series
+  }
+
+  offset, scale := 0.0, 0.0
+  switch mode {
+  case "maxpct":
+    for _, value := range values {
+      scale = math.Max(scale, math.Abs(value))
+    }
+    scale /= 100
+  case "zscore":
+    offset = sum(values) / float64(len(values))
+    for _, value := range values {
+      scale += (value - offset) * (value - offset)
+    }
+    scale = math.Sqrt(scale / float64(len(values)))
+  }
+  if scale == 0 {
+    return series
+  }
+
+  normalized := make(Series, len(series))
+  for i, point := range series {
+    point.Value = (point.Value - offset) / scale
+    normalized[i] = point
+  }
+
+  return normalized
+}
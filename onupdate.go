@@ -0,0 +1,34 @@
+package main
+
+import (
+  "errors"
+  "os"
+  "os/exec"
+  "strconv"
+  "time"
+)
+
+// Run -on-update for the panel's latest real datapoint, with its value, timestamp, and metric as $1, $2, and $3 and as CW_TOP_VALUE, CW_TOP_TIMESTAMP, and CW_TOP_METRIC.
+// It runs to completion before the next poll is handled, so a slow command delays the tail rather than piling up. Its output goes to stderr, keeping stdout for the graph.
+func runOnUpdate(options Options, panel Panel) {
+  point, ok := panel.Series.Last()
+  if !ok {
+    return
+  }
+
+  value, timestamp := strconv.FormatFloat(point.Value, 'f', -1, 64), point.Timestamp.Format(time.RFC3339)
+  cmd := shellCommand(options.onUpdate, value, timestamp, panel.Label)
+  cmd.Env = append(os.Environ(), "CW_TOP_VALUE=" + value, "CW_TOP_TIMESTAMP=" + timestamp, "CW_TOP_METRIC=" + panel.Label)
+  cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+
+  err := cmd.Run()
+  var exitErr *exec.ExitError
+  switch {
+  case errors.As(err, &exitErr):
+    warnf("Warning: -on-update for %s exited with status %d", panel.Label, exitErr.ExitCode())
+  case err != nil:
+    warnf("Warning: cannot run -on-update: %s", err.Error())
+  case options.info:
+    infof("-on-update for %s=%s exited with status 0", panel.Label, value)
+  }
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+  "bufio"
+  "fmt"
+  "os"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// A metric family of the OpenMetrics exposition: every series sharing a name, which must be written together under one TYPE and HELP
+type openMetricsFamily struct {
+  name string
+  help string
+  samples []openMetricsSample
+}
+
+type openMetricsSample struct {
+  labels string
+  value float64
+  timestamp time.Time
+}
+
+// Print the latest real datapoint of each panel as a gauge in the OpenMetrics text format, e.g. for a textfile collector or a scraper that speaks OpenMetrics.
+// Each gauge is named after its metric, with its dimensions as labels.
+func (client Client) printOpenMetrics(options Options) error {
+  window := -lastValuePeriods * time.Duration(options.period) * time.Second
+  if options.lookback > window {
+    window = options.lookback
+  }
+  end := time.Now()
+  panels, err := client.fetchPanels(options, end.Add(window), end)
+  if err != nil {
+    return err
+  }
+
+  families := []*openMetricsFamily{}
+  byName := map[string]*openMetricsFamily{}
+  // Sanitizing can map different series onto the same name and labels, which would make the exposition invalid
+  exposed := map[string]string{}
+  for _, panel := range panels {
+    point, ok := panel.Series.Last()
+    if !ok {
+      warnf("Warning: no data for %s in the last %s, leaving it out", panel.Label, -window)
+      continue
+    }
+
+    name, help, labels, err := openMetricsSeries(panel)
+    if err != nil {
+      return err
+    }
+    if other, ok := exposed[name + labels]; ok {
+      return fmt.Errorf("%s and %s would both be exposed as %s%s", other, panel.Label, name, labels)
+    }
+    exposed[name + labels] = panel.Label
+
+    family, ok := byName[name]
+    if !ok {
+      family = &openMetricsFamily{ name: name, help: help }
+      byName[name] = family
+      families = append(families, family)
+    }
+    family.samples = append(family.samples, openMetricsSample{ labels: labels, value: point.Value, timestamp: point.Timestamp })
+  }
+
+  writer := bufio.NewWriter(os.Stdout)
+  for _, family := range families {
+    fmt.Fprintf(writer, "# TYPE %s gauge\n", family.name)
+    fmt.Fprintf(writer, "# HELP %s %s\n", family.name, openMetricsEscape(family.help, false))
+    for _, sample := range family.samples {
+      fmt.Fprintf(writer, "%s%s %s %d\n", family.name, sample.labels, strconv.FormatFloat(sample.value, 'f', -1, 64), sample.timestamp.Unix())
+    }
+  }
+  writer.WriteString("# EOF\n")
+
+  return writer.Flush()
+}
+
+// The gauge's name, help text, and label set for the panel. Derived series have no metric or dimensions, so they're named after their label.
+func openMetricsSeries(panel Panel) (string, string, string, error) {
+  if panel.Query == nil {
+    return openMetricsName(panel.Label, true), panel.Label, "", nil
+  }
+
+  query := panel.Query
+  stat := query.Stat
+  if query.Derive != "" {
+    stat = query.Derive
+  }
+  help := fmt.Sprintf("%s/%s %s over %ds (from CloudWatch)", query.Namespace, query.Metric, stat, query.Period)
+
+  pairs := []string{}
+  seen := map[string]string{}
+  for _, dimension := range query.Dimensions {
+    label := openMetricsName(*dimension.Name, false)
+    if other, ok := seen[label]; ok {
+      return "", "", "", fmt.Errorf("dimensions %s and %s of %s would both be exposed as the label %s", other, *dimension.Name, panel.Label, label)
+    }
+    seen[label] = *dimension.Name
+    pairs = append(pairs, label + "=\"" + openMetricsEscape(*dimension.Value, true) + "\"")
+  }
+  labels := ""
+  if len(pairs) > 0 {
+    labels = "{" + strings.Join(pairs, ",") + "}"
+  }
+
+  return openMetricsName(query.Metric, true), help, labels, nil
+}
+
+// Replace each character OpenMetrics doesn't allow in a metric or label name with an underscore: letters, digits, and underscores, and colons in metric names only.
+// A leading digit gets an underscore in front, and label names can't start with the reserved double underscore.
+func openMetricsName(raw string, metric bool) string {
+  var builder strings.Builder
+  for i, r := range raw {
+    switch {
+    case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':' && metric:
+      builder.WriteRune(r)
+    case r >= '0' && r <= '9':
+      if i == 0 {
+        builder.WriteRune('_')
+      }
+      builder.WriteRune(r)
+    default:
+      builder.WriteRune('_')
+    }
+  }
+
+  name := builder.String()
+  if name == "" {
+    return "_"
+  }
+  if !metric && strings.HasPrefix(name, "__") {
+    name = strings.TrimLeft(name, "_")
+    if name == "" || (name[0] >= '0' && name[0] <= '9') {
+      name = "_" + name
+    }
+  }
+
+  return name
+}
+
+// Backslash-escape newlines and backslashes, and double quotes too in label values
+func openMetricsEscape(raw string, quoted bool) string {
+  replacements := []string{ "\\", "\\\\", "\n", "\\n" }
+  if quoted {
+    replacements = append(replacements, "\"", "\\\"")
+  }
+
+  return strings.NewReplacer(replacements...).Replace(raw)
+}
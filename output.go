@@ -0,0 +1,235 @@
+package main
+
+import (
+  "bufio"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "math"
+  "os"
+  "strconv"
+  "strings"
+  "time"
+
+  "golang.org/x/crypto/ssh/terminal"
+)
+
+// Values accepted by -output
+var outputFormats = []string{ "graph", "last", "jsonl", "influx", "chartjson", "gnuplot", "values", "openmetrics" }
+
+func validOutput(name string) bool {
+  for _, format := range outputFormats {
+    if name == format {
+      return true
+    }
+  }
+
+  return false
+}
+
+// One datapoint as emitted by the JSON outputs
+type jsonDatapoint struct {
+  Metric string `json:"metric"`
+  Timestamp time.Time `json:"timestamp"`
+  Value float64 `json:"value"`
+  // Set on gap-filled buckets -save-baseline keeps so the baseline lines up, and left out for real datapoints
+  Filled bool `json:"filled,omitempty"`
+}
+
+// Number of periods fetched for -output last. Enough to ride out a late publish while staying cheap.
+const lastValuePeriods = 10
+
+// Print the most recent real datapoint of each panel, one per line
+func (client Client) printLastValue(options Options) error {
+  window := -lastValuePeriods * time.Duration(options.period) * time.Second
+  if options.lookback > window {
+    window = options.lookback
+  }
+  end := time.Now()
+  panels, err := client.fetchPanels(options, end.Add(window), end)
+  if err != nil {
+    return err
+  }
+
+  for _, panel := range panels {
+    point, ok := panel.Series.Last()
+    if !ok {
+      return fmt.Errorf("%w for %s in the last %s", ErrNoData, panel.Label, -window)
+    }
+
+    line := strconv.FormatFloat(point.Value, 'f', -1, 64)
+    if options.info {
+      line = point.Timestamp.Format(time.RFC3339) + " " + line
+    }
+    if len(panels) > 1 {
+      line = panel.Label + " " + line
+    }
+    fmt.Println(line)
+  }
+
+  return nil
+}
+
+// A panel as JS asciichart takes it: plot(series, config). Breaks are null, since JSON has no NaN.
+type chartJSON struct {
+  Series [][]*float64 `json:"series"`
+  Config struct {
+    Height int `json:"height"`
+  } `json:"config"`
+  // Suggested width in columns, which asciichart leaves to the caller via the series length
+  Width int `json:"width"`
+  Caption string `json:"caption"`
+}
+
+// Print each panel, transformed as it would be drawn, as a JSON object per line for re-rendering with JS asciichart
+func (client Client) printChartJSON(options Options) error {
+  width, height, err := terminal.GetSize(int(os.Stdin.Fd()))
+  if err != nil {
+    width, height = 80, 20
+  }
+
+  end := time.Now()
+  panels, err := client.fetchPanels(options, end.Add(options.lookback), end)
+  if err != nil {
+    return err
+  }
+
+  encoder := json.NewEncoder(os.Stdout)
+  for _, panel := range transformPanels(options, panels, end) {
+    chart := chartJSON{ Width: width, Caption: panel.caption(options.namespace, options.lookback, end) }
+    chart.Config.Height = height / len(panels)
+    for _, series := range panel.allSeries() {
+      values := make([]*float64, len(series.Series))
+      for i := range series.Series {
+        if !math.IsNaN(series.Series[i].Value) {
+          values[i] = &series.Series[i].Value
+        }
+      }
+      chart.Series = append(chart.Series, values)
+    }
+    if err := encoder.Encode(chart); err != nil {
+      return err
+    }
+  }
+
+  return nil
+}
+
+// Print each panel's values after gap-filling and the display transforms, one per line with nothing else, for piping into awk and the like. Breaks are NaN, and panels are separated by a blank line.
+func (client Client) printValues(options Options) error {
+  start, end := options.window(time.Now())
+  panels, err := client.fetchPanels(options, start, end)
+  if err != nil {
+    return err
+  }
+
+  writer := bufio.NewWriter(os.Stdout)
+  for i, panel := range transformPanels(options, panels, end) {
+    if i > 0 {
+      writer.WriteString("\n")
+    }
+    for _, point := range panel.Series {
+      writer.WriteString(strconv.FormatFloat(point.Value, 'f', -1, 64) + "\n")
+    }
+  }
+
+  return writer.Flush()
+}
+
+// Emit each real datapoint as a JSON object per line
+func (client Client) streamJSONLines(options Options) error {
+  encoder := json.NewEncoder(os.Stdout)
+  return client.streamDatapoints(options, func (panel Panel, point Point) error {
+    return encoder.Encode(jsonDatapoint{ Metric: panel.Label, Timestamp: point.Timestamp, Value: point.Value })
+  })
+}
+
+// Emit each real datapoint in InfluxDB line protocol, e.g. `Invocations,FunctionName=foo Sum=3 1632150000000000000`
+func (client Client) streamInflux(options Options) error {
+  return client.streamDatapoints(options, func (panel Panel, point Point) error {
+    _, err := fmt.Println(influxLine(panel, point))
+    return err
+  })
+}
+
+// The metric is the measurement, its dimensions the tags, and its statistic the field. Derived series have neither, so they go by their label.
+func influxLine(panel Panel, point Point) string {
+  measurement, field := panel.Label, "value"
+  if panel.Query != nil {
+    measurement, field = panel.Query.Metric, panel.Query.Stat
+    if panel.Query.Derive != "" {
+      field = panel.Query.Derive
+    }
+  }
+
+  line := influxEscape(measurement, ", ")
+  if panel.Query != nil {
+    for _, dimension := range panel.Query.Dimensions {
+      line += "," + influxEscape(*dimension.Name, ",= ") + "=" + influxEscape(*dimension.Value, ",= ")
+    }
+  }
+
+  return fmt.Sprintf("%s %s=%s %d", line, influxEscape(field, ",= "), strconv.FormatFloat(point.Value, 'f', -1, 64), point.Timestamp.UnixNano())
+}
+
+// Backslash-escape the characters that are special in this part of a line
+func influxEscape(raw string, special string) string {
+  var builder strings.Builder
+  for _, r := range raw {
+    if strings.ContainsRune(special, r) {
+      builder.WriteRune('\\')
+    }
+    builder.WriteRune(r)
+  }
+
+  return builder.String()
+}
+
+// Pass each real datapoint to write, oldest first. In tail mode each poll only passes on datapoints newer than the last one written for that series.
+func (client Client) streamDatapoints(options Options, write func (panel Panel, point Point) error) error {
+  lastEmitted := map[string]time.Time{}
+  emit := func (panels []Panel) error {
+    for _, panel := range panels {
+      for _, point := range panel.Series {
+        if point.Filled || math.IsNaN(point.Value) || !point.Timestamp.After(lastEmitted[panel.Label]) {
+          continue
+        }
+        if err := write(panel, point); err != nil {
+          return err
+        }
+        lastEmitted[panel.Label] = point.Timestamp
+      }
+    }
+    return nil
+  }
+
+  end := time.Now()
+  panels, err := client.fetchPanels(options, end.Add(options.lookback), end)
+  if err != nil && !(options.tail && errors.Is(err, ErrNoData) && !options.failOnEmpty) {
+    return err
+  }
+  if err := emit(panels); err != nil {
+    return err
+  }
+
+  // Overlap each poll by a period so a datapoint published late for a bucket just before the last poll isn't missed
+  period := time.Duration(options.period) * time.Second
+  for options.tail {
+    time.Sleep(tailInterval(period))
+
+    newEnd := time.Now()
+    panels, err := client.fetchPanels(options, end.Add(-period), newEnd)
+    if errors.Is(err, ErrNoData) {
+      continue
+    }
+    if err != nil {
+      return err
+    }
+    end = newEnd
+    if err := emit(panels); err != nil {
+      return err
+    }
+  }
+
+  return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+  "time"
+)
+
+// Most datapoints CloudWatch returns for one GetMetricStatistics call
+const maxDatapointsPerRequest = 1440
+
+// For -auto-period: the finest period that fits the window into a single request's datapoints and that CloudWatch still keeps data at for the whole window.
+// Never finer than a minute, since only high-resolution custom metrics have anything finer and for everything else a sub-minute period comes back empty.
+func autoPeriod(window time.Duration) int64 {
+  seconds := int64(window.Seconds())
+  needed := (seconds + maxDatapointsPerRequest - 1) / maxDatapointsPerRequest
+  period := (needed + 59) / 60 * 60
+  if period < 60 {
+    period = 60
+  }
+
+  // Step up a retention tier at a time until the window's oldest data is still held
+  for _, tier := range retentionTiers {
+    if period < tier.period && retentionFor(period) < window {
+      period = tier.period
+    }
+  }
+
+  return period
+}
+
+// Values accepted by -on-overflow
+const (
+  overflowSplit = "split"
+  overflowCoarsen = "coarsen"
+)
+
+// Periods CloudWatch accepts below a minute. Above it, any multiple of 60 is valid.
+var subMinutePeriods = []int64{ 1, 5, 10, 30 }
+
+// For -on-overflow coarsen: the finest valid period coarser than period that fits the window into a single request's datapoints
+func coarserPeriod(period int64, window time.Duration) int64 {
+  needed := (int64(window.Seconds()) + maxDatapointsPerRequest - 1) / maxDatapointsPerRequest
+  if needed <= period {
+    needed = period + 1
+  }
+  for _, candidate := range subMinutePeriods {
+    if candidate >= needed {
+      return candidate
+    }
+  }
+
+  return (needed + 59) / 60 * 60
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "math"
+  "os"
+  "time"
+)
+
+// The -probe report. Every field is always present, null when there's nothing to put in it, so scripts can rely on the shape.
+type probeResult struct {
+  // Fetched without error, with datapoints, and not breaching -threshold
+  OK bool `json:"ok"`
+  Metric string `json:"metric"`
+  Latest *float64 `json:"latest"`
+  Timestamp *time.Time `json:"timestamp"`
+  Min *float64 `json:"min"`
+  Max *float64 `json:"max"`
+  Mean *float64 `json:"mean"`
+  // Datapoints from CloudWatch in the window, not counting gap-filled buckets
+  Datapoints int `json:"datapoints"`
+  Threshold *float64 `json:"threshold"`
+  // The latest value is above -threshold, or below it with -threshold-below
+  Breached bool `json:"breached"`
+  Error *string `json:"error"`
+}
+
+// Fetch the -metric and print a probeResult instead of rendering. Failures past parsing the flags are reported in it rather than by the exit status, so a caller only has to check the status for a usage error.
+func (client Client) probe(options Options) error {
+  start, end := options.window(time.Now())
+  panels, err := client.fetchPanels(options, start, end)
+  if err == nil && len(panels) != 1 {
+    err = fmt.Errorf("-probe checks a single series, but the query matched %d", len(panels))
+  }
+  if err != nil {
+    return printProbe(failedProbe(options, err))
+  }
+
+  return printProbe(probeSeries(options, panels[0]))
+}
+
+func probeSeries(options Options, panel Panel) probeResult {
+  result := probeResult{ Metric: panel.Label }
+  if options.threshold.set {
+    result.Threshold = &options.threshold.value
+  }
+
+  min, max, sum := math.Inf(1), math.Inf(-1), 0.0
+  for _, point := range panel.Series {
+    if point.Filled || math.IsNaN(point.Value) {
+      continue
+    }
+    min, max, sum = math.Min(min, point.Value), math.Max(max, point.Value), sum + point.Value
+    result.Datapoints++
+  }
+  if result.Datapoints == 0 {
+    message := fmt.Sprintf("%s for %s in the window", ErrNoData.Error(), panel.Label)
+    result.Error = &message
+    return result
+  }
+
+  latest, _ := panel.Series.Last()
+  mean := sum / float64(result.Datapoints)
+  result.Latest, result.Timestamp, result.Min, result.Max, result.Mean = &latest.Value, &latest.Timestamp, &min, &max, &mean
+  if options.threshold.set {
+    result.Breached = (options.thresholdBelow && latest.Value < options.threshold.value) || (!options.thresholdBelow && latest.Value > options.threshold.value)
+  }
+  result.OK = !result.Breached
+
+  return result
+}
+
+// A report for a probe that got no series at all, e.g. because discovery or the credentials failed
+func failedProbe(options Options, err error) probeResult {
+  result := probeResult{ Metric: options.metrics.String() }
+  if len(options.queries) == 1 {
+    result.Metric = options.queries[0].Label()
+  }
+  if options.threshold.set {
+    result.Threshold = &options.threshold.value
+  }
+  message := err.Error()
+  result.Error = &message
+
+  return result
+}
+
+func printProbe(result probeResult) error {
+  return json.NewEncoder(os.Stdout).Encode(result)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+  "fmt"
+  "strings"
+
+  "github.com/aws/aws-sdk-go/service/cloudwatch"
+)
+
+// A single metric to fetch, i.e. what one panel's series is built from
+type MetricQuery struct {
+  Metric string
+  Namespace string
+  Dimensions []*cloudwatch.Dimension
+  // In seconds
+  Period int64
+  // A CloudWatch statistic or percentile
+  Stat string
+  // Empty, or deriveAverage
+  Derive string
+}
+
+func (query MetricQuery) Label() string {
+  if len(query.Dimensions) == 0 {
+    return query.Metric
+  }
+
+  pairs := []string{}
+  for _, dimension := range query.Dimensions {
+    pairs = append(pairs, *dimension.Name + "=" + *dimension.Value)
+  }
+
+  return fmt.Sprintf("%s{%s}", query.Metric, strings.Join(pairs, ","))
+}
+
+// Parse `Name=Value` pairs from -dimension flags
+func parseDimensions(raw []string) ([]*cloudwatch.Dimension, error) {
+  var dimensions []*cloudwatch.Dimension
+  for _, pair := range raw {
+    parts := strings.SplitN(pair, "=", 2)
+    if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+      return dimensions, fmt.Errorf("invalid -dimension %q, expected Name=Value", pair)
+    }
+    name, value := parts[0], parts[1]
+    dimensions = append(dimensions, &cloudwatch.Dimension{ Name: &name, Value: &value })
+  }
+
+  return dimensions, nil
+}
+
+func withDimension(dimensions []*cloudwatch.Dimension, name string, value string) []*cloudwatch.Dimension {
+  extended := append([]*cloudwatch.Dimension{}, dimensions...)
+  return append(extended, &cloudwatch.Dimension{ Name: &name, Value: &value })
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+  "math"
+  "sync"
+  "time"
+)
+
+// Token bucket capping metric API calls per second, so a large split or many metrics don't trip account-wide throttling for everything else sharing the account
+type rateLimiter struct {
+  mutex sync.Mutex
+  perSecond float64
+  burst float64
+  tokens float64
+  refilled time.Time
+}
+
+// Up to perSecond calls may go out at once, and then perSecond a second after that
+func newRateLimiter(perSecond float64) *rateLimiter {
+  burst := math.Max(1, perSecond)
+  return &rateLimiter{ perSecond: perSecond, burst: burst, tokens: burst, refilled: time.Now() }
+}
+
+// Block until a call may be made. Each caller reserves its token up front, so concurrent callers queue up rather than racing for the next one.
+func (limiter *rateLimiter) wait() {
+  limiter.mutex.Lock()
+  now := time.Now()
+  limiter.tokens = math.Min(limiter.burst, limiter.tokens + now.Sub(limiter.refilled).Seconds() * limiter.perSecond)
+  limiter.refilled = now
+  limiter.tokens--
+  deficit := -limiter.tokens
+  limiter.mutex.Unlock()
+
+  if deficit > 0 {
+    time.Sleep(time.Duration(deficit / limiter.perSecond * float64(time.Second)))
+  }
+}
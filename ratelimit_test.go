@@ -0,0 +1,36 @@
+package main
+
+import (
+  "sync"
+  "testing"
+  "time"
+)
+
+func TestRateLimiterWait(t *testing.T) {
+  const perSecond, workers, callsEach = 50.0, 8, 12
+  limiter := newRateLimiter(perSecond)
+
+  started := time.Now()
+  var wg sync.WaitGroup
+  for i := 0; i < workers; i++ {
+    wg.Add(1)
+    go func () {
+      defer wg.Done()
+      for j := 0; j < callsEach; j++ {
+        limiter.wait()
+      }
+    }()
+  }
+  wg.Wait()
+  elapsed := time.Since(started)
+
+  // The first burst goes out at once, and every call after it has to wait for a token
+  calls := float64(workers * callsEach)
+  minimum := time.Duration((calls - limiter.burst) / perSecond * float64(time.Second))
+  if elapsed < minimum - 50 * time.Millisecond {
+    t.Errorf("%g calls took %s, but at %g a second after a burst of %g they need at least %s", calls, elapsed, perSecond, limiter.burst, minimum)
+  }
+  if elapsed > 2 * minimum + time.Second {
+    t.Errorf("%g calls took %s, far longer than the %s the rate allows", calls, elapsed, minimum)
+  }
+}
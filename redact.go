@@ -0,0 +1,84 @@
+package main
+
+import (
+  "fmt"
+  "strings"
+)
+
+// Set by -redact, to hide what's being graphed when sharing a screen: names are replaced with placeholders and timestamps hidden, leaving the shape of the graph
+var redact bool
+
+// Shown in place of every timestamp under -redact
+const redactedTime = "[redacted]"
+
+// Copies of the panels with every label replaced by a numbered placeholder, in captions, footers, and events alike, and the placeholder for the namespace.
+// Custom caption templates are dropped since they can print the window's end regardless.
+func redactPanels(panels []Panel, namespace string) ([]Panel, string) {
+  placeholders := map[string]string{}
+  names := []string{}
+  var collect func (panel Panel)
+  collect = func (panel Panel) {
+    for _, name := range []string{ panel.Label, panel.Title } {
+      if _, ok := placeholders[name]; name != "" && !ok {
+        placeholders[name] = fmt.Sprintf("metric-%d", len(placeholders) + 1)
+        names = append(names, name)
+      }
+    }
+    for _, overlay := range panel.Overlays {
+      collect(overlay)
+    }
+  }
+  for _, panel := range panels {
+    collect(panel)
+  }
+  if namespace != "" {
+    names = append(names, namespace)
+    placeholders[namespace] = "namespace"
+  }
+
+  // Longest first, so a label containing another isn't left half replaced
+  replacements := []string{}
+  for len(names) > 0 {
+    longest := 0
+    for i, name := range names {
+      if len(name) > len(names[longest]) {
+        longest = i
+      }
+    }
+    replacements = append(replacements, names[longest], placeholders[names[longest]])
+    names = append(names[:longest], names[longest + 1:]...)
+  }
+  replacer := strings.NewReplacer(replacements...)
+
+  var redactPanel func (panel Panel) Panel
+  redactPanel = func (panel Panel) Panel {
+    panel.Label = placeholders[panel.Label]
+    if panel.Title != "" {
+      panel.Title = placeholders[panel.Title]
+    }
+    panel.CaptionTemplate = nil
+    footer := make([]string, len(panel.Footer))
+    for i, line := range panel.Footer {
+      footer[i] = replacer.Replace(line)
+    }
+    panel.Footer = footer
+    events := make([]Event, len(panel.Events))
+    for i, event := range panel.Events {
+      events[i] = Event{ At: event.At, Label: "event" }
+    }
+    panel.Events = events
+    overlays := make([]Panel, len(panel.Overlays))
+    for i, overlay := range panel.Overlays {
+      overlays[i] = redactPanel(overlay)
+    }
+    panel.Overlays = overlays
+    return panel
+  }
+
+  redacted := make([]Panel, len(panels))
+  for i, panel := range panels {
+    redacted[i] = redactPanel(panel)
+  }
+
+  return redacted, placeholders[namespace]
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+  "encoding/json"
+  "net/http"
+  "os"
+  "strings"
+  "time"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/aws/ec2metadata"
+  "github.com/aws/aws-sdk-go/aws/session"
+)
+
+// Used when nothing else says which region to query
+const defaultRegion = "us-east-1"
+
+// Metadata endpoints are link-local, so anything slower than this means we aren't on AWS compute
+const metadataTimeout = time.Second
+
+// Pick the region from -region, then AWS_REGION, then ECS or EC2 instance metadata, and only then fall back to the default
+func resolveRegion(options Options) string {
+  region, source := regionWithSource(options)
+  if source == "" {
+    warnf("Warning: no -region or AWS_REGION set and no instance metadata available, defaulting to %s", defaultRegion)
+  }
+
+  return region
+}
+
+// The region and where it came from. The source is empty if nothing set it and it's the default.
+func regionWithSource(options Options) (string, string) {
+  if options.region != "" {
+    return options.region, "-region"
+  }
+  if region := os.Getenv("AWS_REGION"); region != "" {
+    return region, "AWS_REGION"
+  }
+  if region, ok := ecsRegion(); ok {
+    return region, "ECS task metadata"
+  }
+  if region, ok := ec2Region(); ok {
+    return region, "EC2 instance metadata"
+  }
+
+  return defaultRegion, ""
+}
+
+// ECS tasks are told where their metadata lives, and the task's availability zone gives away its region
+func ecsRegion() (string, bool) {
+  endpoint := os.Getenv("ECS_CONTAINER_METADATA_URI_V4")
+  if endpoint == "" {
+    return "", false
+  }
+
+  response, err := (&http.Client{ Timeout: metadataTimeout }).Get(endpoint + "/task")
+  if err != nil {
+    return "", false
+  }
+  defer response.Body.Close()
+
+  task := struct {
+    AvailabilityZone string
+  }{}
+  if err := json.NewDecoder(response.Body).Decode(&task); err != nil || len(task.AvailabilityZone) < 2 {
+    return "", false
+  }
+
+  return strings.TrimRight(task.AvailabilityZone, "abcdefghijklmnopqrstuvwxyz"), true
+}
+
+func ec2Region() (string, bool) {
+  sess, err := session.NewSession(&aws.Config{ MaxRetries: aws.Int(0) })
+  if err != nil {
+    return "", false
+  }
+
+  metadata := ec2metadata.New(sess, &aws.Config{ HTTPClient: &http.Client{ Timeout: metadataTimeout } })
+  region, err := metadata.Region()
+  if err != nil {
+    return "", false
+  }
+
+  return region, true
+}
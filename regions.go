@@ -0,0 +1,127 @@
+package main
+
+import (
+  "fmt"
+  "math"
+  "sort"
+  "strconv"
+  "time"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/aws/endpoints"
+  "github.com/aws/aws-sdk-go/service/cloudwatch"
+  "github.com/guptarohit/asciigraph"
+)
+
+// Deviations from the median region beyond these percentages are shown as warm and hot
+const (
+  regionWarmDeviation = 10.0
+  regionHotDeviation = 50.0
+)
+
+// A copy of the client talking to CloudWatch in another region, sharing the request count and rate limit.
+// The session's endpoint is fixed to its own region, so the other region's has to be resolved explicitly.
+func (client Client) inRegion(region string, fips bool) (Client, error) {
+  var endpoint string
+  if fips {
+    resolved, err := fipsEndpoint(region)
+    if err != nil {
+      return client, err
+    }
+    endpoint = resolved
+  } else {
+    resolved, err := endpoints.DefaultResolver().EndpointFor(cloudwatch.EndpointsID, region)
+    if err != nil {
+      return client, fmt.Errorf("unknown region %s: %w", region, err)
+    }
+    endpoint = resolved.URL
+  }
+
+  regional := client
+  regional.connection = client.instrument(cloudwatch.New(client.session, &aws.Config{ Region: aws.String(region), Endpoint: aws.String(endpoint) }))
+  return regional, nil
+}
+
+// Show the metric's value in each of -compare-regions, colored by how far it strays from the median region, so a hot region stands out
+func (client Client) renderRegions(options Options) error {
+  clients := map[string]Client{}
+  for _, region := range options.compareRegions {
+    regional, err := client.inRegion(region, options.fips)
+    if err != nil {
+      return err
+    }
+    clients[region] = regional
+  }
+
+  for {
+    end := time.Now()
+    rows := []topRow{}
+    for _, region := range options.compareRegions {
+      regionRows, err := clients[region].fetchTopRows(options, options.queries, end.Add(options.lookback), end)
+      if err != nil {
+        return fmt.Errorf("%s: %w", region, err)
+      }
+      if len(regionRows) == 0 {
+        warnf("Warning: no data for %s in %s", options.queries[0].Label(), region)
+        continue
+      }
+      row := regionRows[0]
+      row.label = region
+      rows = append(rows, row)
+    }
+    if len(rows) == 0 {
+      return fmt.Errorf("%w for %s in any of %d regions", ErrNoData, options.queries[0].Label(), len(options.compareRegions))
+    }
+    sort.SliceStable(rows, func (i, j int) bool {
+      return rows[i].value > rows[j].value
+    })
+
+    if options.tail {
+      asciigraph.Clear()
+    }
+    printRegions(options, rows, end)
+    if !options.tail {
+      return nil
+    }
+    time.Sleep(tailInterval(time.Duration(options.period) * time.Second))
+  }
+}
+
+func printRegions(options Options, rows []topRow, end time.Time) {
+  values := []float64{}
+  for _, row := range rows {
+    values = append(values, row.value)
+  }
+  median := medianOf(values)
+
+  // The median is over every region, even those cut to fit the terminal
+  rows = fitRows(rows)
+
+  fmt.Printf("[%s] %s by %s across %d regions, median %s (last updated at %s)\n", options.namespace, options.queries[0].Label(), options.topBy, len(values), strconv.FormatFloat(median, 'f', -1, 64), formatTime(end))
+  for _, row := range rows {
+    deviation := 0.0
+    if median != 0 {
+      deviation = (row.value - median) / math.Abs(median) * 100
+    }
+    color := asciigraph.Green
+    switch {
+    case math.Abs(deviation) >= regionHotDeviation:
+      color = asciigraph.Red
+    case math.Abs(deviation) >= regionWarmDeviation:
+      color = asciigraph.Yellow
+    }
+    fmt.Printf("%-16s  %12s  %s%+7.1f%%%s  %-*s\n", row.label, strconv.FormatFloat(row.value, 'f', -1, 64), color, deviation, asciigraph.Default, topSparklineWidth, sparkline(row.series.Values(), topSparklineWidth))
+  }
+}
+
+// The middle value, or the mean of the middle two
+func medianOf(values []float64) float64 {
+  sorted := append([]float64{}, values...)
+  sort.Float64s(sorted)
+  middle := len(sorted) / 2
+  if len(sorted) % 2 == 0 {
+    return (sorted[middle - 1] + sorted[middle]) / 2
+  }
+
+  return sorted[middle]
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+  "fmt"
+  "math"
+  "sort"
+  "strconv"
+  "time"
+)
+
+// Values accepted by -regression-stat: how each window is summarized before comparing
+var regressionStats = map[string]func (sorted []float64) float64{
+  "mean": func (sorted []float64) float64 {
+    return sum(sorted) / float64(len(sorted))
+  },
+  "max": func (sorted []float64) float64 {
+    return sorted[len(sorted) - 1]
+  },
+  // Nearest rank
+  "p95": func (sorted []float64) float64 {
+    return sorted[int(math.Ceil(0.95 * float64(len(sorted)))) - 1]
+  },
+}
+
+// Compare the current window of each metric against its -baseline, printing the comparison and failing with ErrRegression if any rose by more than -regression-threshold percent
+func (client Client) checkRegression(options Options) error {
+  start, end := options.window(time.Now())
+  panels, err := client.fetchPanels(options, start, end)
+  if err != nil {
+    return err
+  }
+
+  summarize := regressionStats[options.regressionStat]
+  regressed := 0
+  for _, panel := range panels {
+    baseline, ok := options.baseline[panel.Label]
+    if !ok {
+      return fmt.Errorf("-baseline has no series for %s", panel.Label)
+    }
+    currentValues, baselineValues := sortedValues(panel.Series), sortedValues(baseline)
+    if len(currentValues) == 0 {
+      return fmt.Errorf("%w for %s in the last %s", ErrNoData, panel.Label, -options.lookback)
+    }
+    if len(baselineValues) == 0 {
+      return fmt.Errorf("-baseline series for %s has no datapoints", panel.Label)
+    }
+
+    current, previous := summarize(currentValues), summarize(baselineValues)
+    change := (current - previous) / math.Abs(previous) * 100
+    if previous == 0 {
+      change = 0
+      if current != 0 {
+        change = math.Copysign(math.Inf(1), current)
+      }
+    }
+    verdict := "ok"
+    if change > options.regressionThreshold.value {
+      verdict = "REGRESSION"
+      regressed++
+    }
+    fmt.Printf("%s %s %s: %s vs baseline %s (%+.1f%%, threshold %s%%) %s\n", formatTime(end), panel.Label, options.regressionStat, strconv.FormatFloat(current, 'f', -1, 64), strconv.FormatFloat(previous, 'f', -1, 64), change, options.regressionThreshold.String(), verdict)
+  }
+  if regressed > 0 {
+    return fmt.Errorf("%w: %d of %d metric(s) above the baseline by more than %s%%", ErrRegression, regressed, len(panels), options.regressionThreshold.String())
+  }
+
+  return nil
+}
+
+// The series' real values in ascending order, without breaks or gap-filled buckets
+func sortedValues(series Series) []float64 {
+  values := []float64{}
+  for _, point := range series {
+    if !point.Filled && !math.IsNaN(point.Value) {
+      values = append(values, point.Value)
+    }
+  }
+  sort.Float64s(values)
+
+  return values
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+  "fmt"
+  "path/filepath"
+  "sort"
+  "time"
+)
+
+// Draw the series saved in -from-file with the usual display options, without touching AWS
+func renderFromFile(options Options) error {
+  labels, saved, err := readDatapoints(options.fromFile)
+  if err != nil {
+    return fmt.Errorf("cannot read -from-file: %w", err)
+  }
+
+  panels := []Panel{}
+  var start, end time.Time
+  for _, label := range labels {
+    // -save-baseline keeps its gap-filled buckets, which are filled in again below with this run's -fill
+    points := Series{}
+    for _, point := range saved[label] {
+      if !point.Filled {
+        points = append(points, point)
+      }
+    }
+    if len(points) == 0 {
+      warnf("Warning: %s has only gap-filled buckets in %s, so it's left out", label, options.fromFile)
+      continue
+    }
+    sort.Slice(points, func (i, j int) bool {
+      return points[i].Timestamp.Before(points[j].Timestamp)
+    })
+    // -output jsonl leaves out filled buckets, so fill them back in at -period
+    series := fillGaps(points, points[0].Timestamp, time.Duration(options.period) * time.Second, options.fillPolicy())
+    if options.trimZeros {
+      series = series.TrimFilled()
+    }
+    panels = append(panels, Panel{ Label: label, Series: series })
+
+    if start.IsZero() || points[0].Timestamp.Before(start) {
+      start = points[0].Timestamp
+    }
+    if last := points[len(points) - 1].Timestamp; last.After(end) {
+      end = last
+    }
+  }
+  if len(panels) == 0 {
+    return fmt.Errorf("%w in %s, only gap-filled buckets", ErrNoData, options.fromFile)
+  }
+  if !options.stacked && len(panels) > 1 {
+    // One panel with the rest drawn over it, rather than several squeezed into the height
+    panels = []Panel{ { Label: panels[0].Label, Series: panels[0].Series, Overlays: panels[1:] } }
+  }
+
+  end = end.Add(time.Duration(options.period) * time.Second)
+  // The file stands in for the namespace in the caption, since that's where the data came from
+  return render(transformPanels(options, panels, end), filepath.Base(options.fromFile), start.Sub(end), end)
+}
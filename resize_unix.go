@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+  "os"
+  "os/signal"
+  "syscall"
+)
+
+// Deliver a signal on the channel whenever the terminal is resized
+func notifyOnResize(resized chan os.Signal) {
+  signal.Notify(resized, syscall.SIGWINCH)
+}
@@ -0,0 +1,12 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+  "os"
+)
+
+// Windows has no SIGWINCH, so resizes are only picked up on the next poll
+func notifyOnResize(resized chan os.Signal) {
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+  "errors"
+  "fmt"
+  "time"
+
+  "github.com/aws/aws-sdk-go/service/cloudwatch"
+)
+
+// With -variable-resolution, data older than each age is fetched at no finer than the tier's period, oldest tier first
+var resolutionTiers = []struct {
+  age time.Duration
+  period int64
+}{
+  { age: 24 * time.Hour, period: 3600 },
+  { age: 3 * time.Hour, period: 300 },
+}
+
+// Split the request's window into consecutive segments by age, each with the period for its tier. Ages are relative to the end of the window.
+func resolutionSegments(request *cloudwatch.GetMetricStatisticsInput) []cloudwatch.GetMetricStatisticsInput {
+  segments := []cloudwatch.GetMetricStatisticsInput{}
+  segmentStart := *request.StartTime
+  for _, tier := range resolutionTiers {
+    period := tier.period
+    if *request.Period > period {
+      period = *request.Period
+    }
+    // Snap to the tier's period so the coarse buckets don't straddle the boundary
+    boundary := request.EndTime.Add(-tier.age).Truncate(time.Duration(period) * time.Second)
+    if !boundary.After(segmentStart) {
+      continue
+    }
+
+    segment := *request
+    start, end := segmentStart, boundary
+    segment.StartTime, segment.EndTime, segment.Period = &start, &end, &period
+    segments = append(segments, segment)
+    segmentStart = boundary
+  }
+
+  segment := *request
+  start := segmentStart
+  segment.StartTime = &start
+  return append(segments, segment)
+}
+
+// Fetch each segment at its own period and stitch the results together, oldest first
+func (client Client) getVariableResolutionSampleCounts(segments []cloudwatch.GetMetricStatisticsInput, fill FillPolicy) (Series, error) {
+  fixed := client
+  fixed.variableResolution = false
+
+  series := Series{}
+  for i := range segments {
+    segmentSeries, err := fixed.getMetricSampleCounts(&segments[i], fill)
+    if errors.Is(err, ErrNoData) {
+      continue
+    }
+    if err != nil {
+      return series, err
+    }
+    series = append(series, segmentSeries...)
+  }
+  if len(series) == 0 {
+    first, last := segments[0], segments[len(segments) - 1]
+    return series, fmt.Errorf("%w for %s/%s between %s and %s", ErrNoData, *first.Namespace, *first.MetricName, first.StartTime, last.EndTime)
+  }
+
+  return series, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+  "fmt"
+  "time"
+
+  "github.com/aws/aws-sdk-go/service/cloudwatch"
+)
+
+// How long CloudWatch keeps datapoints at each resolution. Periods finer than a tier's are only available for its retention, after which the data is rolled up to the next tier.
+var retentionTiers = []struct {
+  period int64
+  retention time.Duration
+}{
+  { period: 60, retention: 3 * time.Hour },
+  { period: 300, retention: 15 * 24 * time.Hour },
+  { period: 3600, retention: 63 * 24 * time.Hour },
+}
+
+// The oldest data CloudWatch still holds at an hourly period
+const maxRetention = 455 * 24 * time.Hour
+
+// How far back datapoints with the given period go
+func retentionFor(period int64) time.Duration {
+  for _, tier := range retentionTiers {
+    if period < tier.period {
+      return tier.retention
+    }
+  }
+
+  return maxRetention
+}
+
+// Reject windows older than -max-window, which defaults to what CloudWatch keeps at -period. An explicitly larger -max-window is allowed with a warning, since the old end of the window is trimmed.
+func checkRetention(options Options) error {
+  // -compare reaches further back than the lookback itself
+  window := -options.lookback + options.compare
+  retention := retentionFor(options.period)
+  if options.variableResolution {
+    retention = retentionFor(resolutionTiers[0].period)
+  }
+
+  maxWindow := options.maxWindow
+  if maxWindow == 0 {
+    maxWindow = retention
+  }
+  if window > maxWindow {
+    return fmt.Errorf("%w: lookback %s exceeds the %s -max-window for a %ds period; use a coarser -period, -variable-resolution, or raise -max-window", ErrInvalidWindow, window, maxWindow, options.period)
+  }
+  if window > retention {
+    warnf("Warning: CloudWatch only keeps %ds datapoints for %s, so the oldest %s of the window will be left out", options.period, retention, window - retention)
+  }
+
+  return nil
+}
+
+// The start of the oldest bucket CloudWatch still holds in full at the given period
+func retentionBoundary(period int64, now time.Time) time.Time {
+  width := time.Duration(period) * time.Second
+  return now.Add(-retentionFor(period)).Truncate(width).Add(width)
+}
+
+// CloudWatch returns nothing for the part of a request older than the retention for its period, which gap-filling would draw as a long run of zeroes that looks like an outage.
+// Move the start up to the retention boundary instead. ok is false if none of the window is retained.
+func trimToRetention(request *cloudwatch.GetMetricStatisticsInput, now time.Time) (trimmed bool, ok bool) {
+  boundary := retentionBoundary(*request.Period, now)
+  if !request.StartTime.Before(boundary) {
+    return false, true
+  }
+  if !request.EndTime.After(boundary) {
+    return true, false
+  }
+
+  request.StartTime = &boundary
+  return true, true
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+  "strings"
+  "sync"
+  "sync/atomic"
+  "time"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/cloudwatch"
+)
+
+// How often -self-metrics reports while tailing, since a tail only ends when it's interrupted
+const selfMetricsInterval = 5 * time.Minute
+
+// Publishes the client's own metric API usage to -self-metrics, each report covering the calls since the last
+type selfMetricsReporter struct {
+  client Client
+  namespace string
+  mutex sync.Mutex
+  // Counts as of the last report
+  statisticsCalls, dataCalls, latency int64
+}
+
+func newSelfMetricsReporter(client Client, namespace string) *selfMetricsReporter {
+  return &selfMetricsReporter{ client: client, namespace: namespace }
+}
+
+// Report every selfMetricsInterval until the process exits
+func (reporter *selfMetricsReporter) reportPeriodically() {
+  for {
+    time.Sleep(selfMetricsInterval)
+    reporter.report()
+  }
+}
+
+// Publish the calls made since the last report. Failing to is only worth a warning, since the run itself went fine.
+func (reporter *selfMetricsReporter) report() {
+  reporter.mutex.Lock()
+  defer reporter.mutex.Unlock()
+
+  statisticsCalls, dataCalls, latency := atomic.LoadInt64(reporter.client.requestCount), atomic.LoadInt64(reporter.client.dataRequestCount), atomic.LoadInt64(reporter.client.fetchLatency)
+  now := time.Now()
+  datum := func (name string, value float64, unit string) *cloudwatch.MetricDatum {
+    return &cloudwatch.MetricDatum{ MetricName: aws.String(name), Value: aws.Float64(value), Unit: aws.String(unit), Timestamp: &now }
+  }
+  input := cloudwatch.PutMetricDataInput{
+    Namespace: aws.String(reporter.namespace),
+    MetricData: []*cloudwatch.MetricDatum{
+      datum("GetMetricStatisticsCalls", float64(statisticsCalls - reporter.statisticsCalls), cloudwatch.StandardUnitCount),
+      datum("GetMetricDataCalls", float64(dataCalls - reporter.dataCalls), cloudwatch.StandardUnitCount),
+      datum("FetchLatency", float64(time.Duration(latency - reporter.latency)) / float64(time.Millisecond), cloudwatch.StandardUnitMilliseconds),
+    },
+  }
+  if _, err := reporter.client.connection.PutMetricData(&input); err != nil {
+    warnf("Warning: failed to publish -self-metrics to %s: %s", reporter.namespace, classifyAWSError(err).Error())
+    return
+  }
+  reporter.statisticsCalls, reporter.dataCalls, reporter.latency = statisticsCalls, dataCalls, latency
+}
+
+// PutMetricData refuses namespaces starting with AWS/, which are reserved for AWS services
+func validSelfMetricsNamespace(namespace string) bool {
+  return namespace != "" && !strings.HasPrefix(namespace, "AWS/")
+}
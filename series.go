@@ -0,0 +1,175 @@
+package main
+
+import (
+  "math"
+  "text/template"
+  "time"
+)
+
+// Point is a single bucket of a series. Filled marks buckets that were inserted to cover a gap rather than returned by CloudWatch.
+type Point struct {
+  Timestamp time.Time
+  Value float64
+  Filled bool
+  // Returned by CloudWatch but hidden as a break, since it averages fewer than -min-samples samples
+  Suppressed bool
+}
+
+type Series []Point
+
+// A labelled series, drawn as its own graph
+type Panel struct {
+  Label string
+  Series Series
+  // Shown in the caption instead of the labels, if set
+  Title string
+  // asciigraph color name, or empty for the default
+  Color string
+  // The metric the series was fetched from, or nil if it was derived from several
+  Query *MetricQuery
+  // Further series drawn on the same axes, e.g. the window being compared against
+  Overlays []Panel
+  // Summary lines printed under the graph
+  Footer []string
+  // Fixed y-axis bounds from -ymin and -ymax
+  YMin optionalFloat
+  YMax optionalFloat
+  // From -caption-template, or nil for the default caption
+  CaptionTemplate *template.Template
+  // From -events, marked where they fall within the window
+  Events []Event
+  // The -normalize mode the series were rescaled by, or empty for raw values
+  Normalized string
+  // The window the panel covers when it isn't -lookback, e.g. with -multi-lookback
+  Lookback time.Duration
+  // Set when the caption should give the window's absolute bounds rather than its lookback, e.g. when stepping with -window-step
+  WindowStart time.Time
+}
+
+// Slide each panel's window forward by the newly fetched buckets, dropping the oldest so no more than limit are kept.
+// The kept buckets are copied so the dropped ones don't linger in a growing backing array over a long tail.
+func slidePanels(panels []Panel, newPanels []Panel, limit int) {
+  for i := range panels {
+    series := append(panels[i].Series, newPanels[i].Series...)
+    if len(series) > limit {
+      series = series[len(series) - limit:]
+    }
+    panels[i].Series = append(Series{}, series...)
+    slidePanels(panels[i].Overlays, newPanels[i].Overlays, limit)
+  }
+}
+
+func (series Series) Values() []float64 {
+  values := make([]float64, len(series))
+  for i, point := range series {
+    values[i] = point.Value
+  }
+
+  return values
+}
+
+// Values accepted by -fill
+const (
+  fillZero = "zero"
+  fillNone = "none"
+  // The last datapoint before the gap, like metric math's FILL(m, REPEAT)
+  fillRepeat = "repeat"
+)
+
+// How gaps between datapoints are filled in
+type FillPolicy struct {
+  // fillZero, fillNone, or fillRepeat
+  Strategy string
+  // Gaps at least this long are left as breaks rather than filled. Zero fills every gap.
+  MaxGap time.Duration
+  // Drop filled buckets before the first and after the last datapoint, e.g. for a metric that only started publishing partway through the window
+  TrimEdges bool
+}
+
+// The value to fill each bucket of a gap of the given length after the previous value with. NaN renders as a break, and is the previous value before the first datapoint.
+func (fill FillPolicy) valueFor(gap time.Duration, previous float64) float64 {
+  if fill.Strategy == fillNone || (fill.MaxGap > 0 && gap >= fill.MaxGap) {
+    return math.NaN()
+  }
+  if fill.Strategy == fillRepeat {
+    return previous
+  }
+
+  return 0
+}
+
+// The series without the filled buckets at either end. Filled buckets between datapoints are kept.
+func (series Series) TrimFilled() Series {
+  first, last := 0, len(series)
+  for first < last && series[first].Filled {
+    first++
+  }
+  for last > first && series[last - 1].Filled {
+    last--
+  }
+
+  return series[first:last]
+}
+
+// Smallest and largest values, ignoring breaks. ok is false if there are no values at all.
+func (series Series) Bounds() (min float64, max float64, ok bool) {
+  min, max = math.Inf(1), math.Inf(-1)
+  for _, point := range series {
+    if math.IsNaN(point.Value) {
+      continue
+    }
+    min = math.Min(min, point.Value)
+    max = math.Max(max, point.Value)
+    ok = true
+  }
+
+  return min, max, ok
+}
+
+// The most recent point that came from CloudWatch rather than gap filling
+func (series Series) Last() (Point, bool) {
+  for i := len(series) - 1; i >= 0; i-- {
+    if !series[i].Filled {
+      return series[i], true
+    }
+  }
+
+  return Point{}, false
+}
+
+// Move every timestamp later by offset, e.g. to line an earlier window up with the current one
+func (series Series) Shift(offset time.Duration) Series {
+  shifted := make(Series, len(series))
+  for i, point := range series {
+    point.Timestamp = point.Timestamp.Add(offset)
+    shifted[i] = point
+  }
+
+  return shifted
+}
+
+// Subtract b from a bucket by bucket. Timestamps present in only one of the series can't be subtracted honestly, so they are dropped and counted as misaligned. A difference is only real data where both buckets are.
+func diffSeries(a Series, b Series) (diff Series, misaligned int) {
+  bByTime := map[int64]Point{}
+  for _, point := range b {
+    bByTime[point.Timestamp.Unix()] = point
+  }
+
+  seen := map[int64]bool{}
+  for _, point := range a {
+    other, ok := bByTime[point.Timestamp.Unix()]
+    if !ok {
+      misaligned++
+      continue
+    }
+    seen[point.Timestamp.Unix()] = true
+    diff = append(diff, Point{
+      Timestamp: point.Timestamp,
+      Value: point.Value - other.Value,
+      Filled: point.Filled || other.Filled,
+    })
+  }
+  misaligned += len(bByTime) - len(seen)
+
+  return diff, misaligned
+}
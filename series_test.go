@@ -0,0 +1,61 @@
+package main
+
+import (
+  "testing"
+  "time"
+)
+
+func TestSlidePanelsStaysBounded(t *testing.T) {
+  period := time.Minute
+  limit := int((3 * time.Hour) / period)
+  start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+  buckets := func (from int, count int) Series {
+    series := Series{}
+    for i := from; i < from + count; i++ {
+      series = append(series, Point{ Timestamp: start.Add(time.Duration(i) * period), Value: float64(i) })
+    }
+    return series
+  }
+
+  panels := []Panel{{ Series: buckets(0, limit), Overlays: []Panel{{ Series: buckets(0, limit) }} }}
+  next := limit
+  // A day and a half of polls, each picking up one to three new buckets like a tail that fell behind now and then
+  for cycle := 0; cycle < 2000; cycle++ {
+    count := 1 + cycle % 3
+    slidePanels(panels, []Panel{{ Series: buckets(next, count), Overlays: []Panel{{ Series: buckets(next, count) }} }}, limit)
+    next += count
+
+    for _, series := range []Series{ panels[0].Series, panels[0].Overlays[0].Series } {
+      if len(series) != limit {
+        t.Fatalf("cycle %d: %d buckets kept, want the %d the window holds", cycle, len(series), limit)
+      }
+      // append rounds the copy's capacity up, but a backing array that kept growing would soon pass twice the window
+      if cap(series) > 2 * limit {
+        t.Fatalf("cycle %d: backing array holds %d buckets for a %d bucket window", cycle, cap(series), limit)
+      }
+      if newest := series[len(series) - 1].Value; newest != float64(next - 1) {
+        t.Fatalf("cycle %d: newest bucket is %g, want %d", cycle, newest, next - 1)
+      }
+    }
+  }
+}
+
+func TestDiffSeriesIsFilledWhereEitherSideIs(t *testing.T) {
+  start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+  at := func (i int) time.Time {
+    return start.Add(time.Duration(i) * time.Minute)
+  }
+  a := Series{{ Timestamp: at(0), Value: 5 }, { Timestamp: at(1), Value: 6 }, { Timestamp: at(2), Value: 0, Filled: true }}
+  // The second bucket is missing from b and was gap-filled with zero
+  b := Series{{ Timestamp: at(0), Value: 1 }, { Timestamp: at(1), Value: 0, Filled: true }, { Timestamp: at(2), Value: 2 }}
+
+  diff, misaligned := diffSeries(a, b)
+  if misaligned != 0 || len(diff) != 3 {
+    t.Fatalf("got %d buckets and %d misaligned, want 3 and 0", len(diff), misaligned)
+  }
+  for i, want := range []bool{ false, true, true } {
+    if diff[i].Filled != want {
+      t.Errorf("bucket %d: filled is %t, want %t", i, diff[i].Filled, want)
+    }
+  }
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+  "errors"
+  "fmt"
+  "net"
+  "net/http"
+  "strconv"
+  "time"
+)
+
+// Size of the plot served when the request doesn't say
+const (
+  defaultServeWidth = 100
+  defaultServeHeight = 25
+  maxServeDimension = 1000
+)
+
+// Serve the rendered graph over HTTP, fetching afresh for every request. Nothing is authenticated, so it only listens on loopback unless given another host.
+func (client Client) serveGraph(options Options) error {
+  address, err := serveAddress(options.serveGraph)
+  if err != nil {
+    return err
+  }
+  http.HandleFunc("/", func (writer http.ResponseWriter, request *http.Request) {
+    graph, status, err := client.graphForRequest(options, request)
+    writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+    if err != nil {
+      writer.WriteHeader(status)
+      fmt.Fprintln(writer, err.Error())
+      return
+    }
+    fmt.Fprintln(writer, graph)
+  })
+
+  warnf("Serving graphs on %s", address)
+  return http.ListenAndServe(address, nil)
+}
+
+// The address to listen on for -serve-graph: a bare :port is loopback only, and any other host is used as given, with a warning if it isn't loopback
+func serveAddress(raw string) (string, error) {
+  host, port, err := net.SplitHostPort(raw)
+  if err != nil {
+    return "", fmt.Errorf("invalid -serve-graph address %q, expected host:port or :port: %w", raw, err)
+  }
+  if host == "" {
+    return net.JoinHostPort("127.0.0.1", port), nil
+  }
+  if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+    warnf("Warning: -serve-graph on %s is unauthenticated, so anyone who can reach it can read its -namespace metrics with your credentials", raw)
+  }
+
+  return raw, nil
+}
+
+// Build a graph from the query string, falling back to the command line flags for anything it leaves out
+func (client Client) graphForRequest(options Options, request *http.Request) (string, int, error) {
+  if request.Method != http.MethodGet {
+    return "", http.StatusMethodNotAllowed, fmt.Errorf("only GET is supported")
+  }
+
+  query := request.URL.Query()
+  metricQuery := options.queries[0]
+  if metric := query.Get("metric"); metric != "" {
+    metricQuery.Metric = metric
+  }
+  // Only the -namespace given on the command line is served, so the port can't be used to read everything the credentials can
+  if namespace := query.Get("namespace"); namespace != "" && namespace != metricQuery.Namespace {
+    return "", http.StatusForbidden, fmt.Errorf("only namespace %s is served", metricQuery.Namespace)
+  }
+
+  lookback := options.lookback
+  if raw := query.Get("lookback"); raw != "" {
+    parsed, err := parseLookback(raw)
+    if err != nil {
+      return "", http.StatusBadRequest, fmt.Errorf("invalid lookback: %w", err)
+    }
+    lookback = parsed
+  }
+  if period := time.Duration(metricQuery.Period) * time.Second; -lookback < period {
+    return "", http.StatusBadRequest, fmt.Errorf("lookback %s is shorter than the %s period", -lookback, period)
+  }
+
+  width, err := dimensionParam(query.Get("width"), defaultServeWidth)
+  if err != nil {
+    return "", http.StatusBadRequest, fmt.Errorf("invalid width: %w", err)
+  }
+  height, err := dimensionParam(query.Get("height"), defaultServeHeight)
+  if err != nil {
+    return "", http.StatusBadRequest, fmt.Errorf("invalid height: %w", err)
+  }
+
+  requestOptions := options
+  requestOptions.queries = []MetricQuery{ metricQuery }
+  requestOptions.namespace = metricQuery.Namespace
+  requestOptions.lookback = lookback
+  requestOptions.diff = false
+
+  end := time.Now()
+  panels, err := client.fetchPanels(requestOptions, end.Add(lookback), end)
+  if err != nil {
+    // AWS errors can name the account and role, so the caller only gets the status
+    status := statusFor(err)
+    warnf("Warning: serving %s: %v", request.URL, err)
+    return "", status, errors.New(http.StatusText(status))
+  }
+
+  return plotPanels(transformPanels(requestOptions, panels, end), metricQuery.Namespace, lookback, end, width, height), http.StatusOK, nil
+}
+
+func dimensionParam(raw string, fallback int) (int, error) {
+  if raw == "" {
+    return fallback, nil
+  }
+
+  value, err := strconv.Atoi(raw)
+  if err != nil {
+    return 0, err
+  }
+  if value < 1 || value > maxServeDimension {
+    return 0, fmt.Errorf("must be between 1 and %d", maxServeDimension)
+  }
+
+  return value, nil
+}
+
+func statusFor(err error) int {
+  switch {
+  case errors.Is(err, ErrNoData):
+    return http.StatusNotFound
+  case errors.Is(err, ErrInvalidWindow):
+    return http.StatusBadRequest
+  case errors.Is(err, ErrThrottled):
+    return http.StatusTooManyRequests
+  case errors.Is(err, ErrAccessDenied):
+    return http.StatusForbidden
+  default:
+    return http.StatusBadGateway
+  }
+}
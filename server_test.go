@@ -0,0 +1,43 @@
+package main
+
+import (
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+  "time"
+)
+
+func TestServeAddress(t *testing.T) {
+  cases := map[string]string{
+    ":8080": "127.0.0.1:8080",
+    "127.0.0.1:9000": "127.0.0.1:9000",
+    "localhost:9000": "localhost:9000",
+    "0.0.0.0:8080": "0.0.0.0:8080",
+  }
+  for raw, want := range cases {
+    if got, err := serveAddress(raw); err != nil || got != want {
+      t.Errorf("serveAddress(%q) = %q, %v, want %q", raw, got, err, want)
+    }
+  }
+  if _, err := serveAddress("8080"); err == nil {
+    t.Errorf("accepted a port without a colon")
+  }
+}
+
+func TestGraphForRequestStaysInNamespaceAndHidesAWSErrors(t *testing.T) {
+  client := newTestClient(t, func (writer http.ResponseWriter, request *http.Request) {
+    errorResponse(writer, http.StatusForbidden, "AccessDenied", "User: arn:aws:iam::123456789012:user/someone is not authorized to perform: cloudwatch:GetMetricStatistics")
+  })
+  options := Options{ period: 60, lookback: -time.Hour, namespace: "Test", queries: []MetricQuery{{ Namespace: "Test", Metric: "Metric", Period: 60, Stat: "Sum" }} }
+
+  _, status, err := client.graphForRequest(options, httptest.NewRequest(http.MethodGet, "/?namespace=AWS/Billing", nil))
+  if status != http.StatusForbidden || err == nil {
+    t.Errorf("another namespace got status %d, %v, want %d", status, err, http.StatusForbidden)
+  }
+
+  _, status, err = client.graphForRequest(options, httptest.NewRequest(http.MethodGet, "/?metric=Other", nil))
+  if err == nil || strings.Contains(err.Error(), "123456789012") || err.Error() != http.StatusText(status) {
+    t.Errorf("failed fetch returned %d %v, want only the status text", status, err)
+  }
+}
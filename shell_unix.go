@@ -0,0 +1,13 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+  "os/exec"
+)
+
+// Run the command line through the shell, with args as $1, $2, and so on
+func shellCommand(command string, args ...string) *exec.Cmd {
+  return exec.Command("sh", append([]string{ "-c", command, "sh" }, args...)...)
+}
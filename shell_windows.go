@@ -0,0 +1,13 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+  "os/exec"
+)
+
+// cmd has no positional parameters, so args are only available through the environment
+func shellCommand(command string, args ...string) *exec.Cmd {
+  return exec.Command("cmd", "/C", command)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+  "fmt"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// Units understood in -since phrases like "2 hours ago", by every spelling accepted
+var sinceUnits = map[string]time.Duration{
+  "second": time.Second, "seconds": time.Second, "sec": time.Second, "secs": time.Second,
+  "minute": time.Minute, "minutes": time.Minute, "min": time.Minute, "mins": time.Minute,
+  "hour": time.Hour, "hours": time.Hour, "hr": time.Hour, "hrs": time.Hour,
+  "day": 24 * time.Hour, "days": 24 * time.Hour,
+  "week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+}
+
+const sinceExamples = `"90 minutes ago", "an hour ago", "last week", "today", "yesterday", "monday", or a timestamp`
+
+// Resolve a -since phrase into the start of the window. Days start at local midnight. Anything that could mean more than one time, like months or "last monday", is refused rather than guessed at.
+func parseSince(phrase string, now time.Time) (time.Time, error) {
+  words := strings.Fields(strings.ToLower(phrase))
+  midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+  if len(words) == 0 {
+    return now, fmt.Errorf("empty -since, expected a phrase like %s", sinceExamples)
+  }
+  for _, word := range words {
+    if strings.HasPrefix(word, "month") || strings.HasPrefix(word, "year") {
+      return now, fmt.Errorf("-since %q is ambiguous since months and years vary in length; use days or weeks", phrase)
+    }
+  }
+
+  switch {
+  case len(words) == 1 && words[0] == "today":
+    return midnight, nil
+  case len(words) == 1 && words[0] == "yesterday":
+    return midnight.AddDate(0, 0, -1), nil
+  case len(words) == 1 && isWeekday(words[0]):
+    // The most recent such day before today, so "monday" on a Monday means a week ago
+    days := (int(now.Weekday()) - int(weekday(words[0])) + 6) % 7 + 1
+    return midnight.AddDate(0, 0, -days), nil
+  case len(words) == 2 && words[0] == "last" && isWeekday(words[1]):
+    return now, fmt.Errorf("-since %q is ambiguous (this week's or the week before?); use just %q for the most recent one", phrase, words[1])
+  case len(words) == 2 && words[0] == "last":
+    if unit, ok := sinceUnits[words[1]]; ok {
+      return now.Add(-unit), nil
+    }
+  case len(words) == 3 && words[2] == "ago":
+    unit, ok := sinceUnits[words[1]]
+    if !ok {
+      break
+    }
+    if words[0] == "a" || words[0] == "an" {
+      return now.Add(-unit), nil
+    }
+    count, err := strconv.ParseFloat(words[0], 64)
+    if err != nil || count <= 0 {
+      break
+    }
+    return now.Add(-time.Duration(count * float64(unit))), nil
+  }
+
+  if at, err := parseTimestamp(strings.TrimSpace(phrase)); err == nil {
+    return at, nil
+  }
+
+  return now, fmt.Errorf("cannot understand -since %q, expected a phrase like %s", phrase, sinceExamples)
+}
+
+func isWeekday(word string) bool {
+  return weekday(word) >= 0
+}
+
+// The weekday named by word, or -1
+func weekday(word string) time.Weekday {
+  for day := time.Sunday; day <= time.Saturday; day++ {
+    if strings.ToLower(day.String()) == word {
+      return day
+    }
+  }
+
+  return -1
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+  "fmt"
+  "os"
+  "path/filepath"
+  "regexp"
+  "sort"
+  "strings"
+  "time"
+)
+
+// Layout of the timestamp in snapshot names, which sorts in time order
+const snapshotTimeLayout = "20060102-1504"
+
+// Characters kept from metric names in snapshot names, so any file system takes them
+var unsafeSnapshotChars = regexp.MustCompile(`[^A-Za-z0-9._+-]`)
+
+// Render the graph at the size -serve-graph defaults to and write it to -snapshot, named by the metrics and the window's end.
+// The size is fixed rather than the terminal's, since cron has no terminal and snapshots of different sizes don't diff.
+func (client Client) writeSnapshot(options Options) error {
+  if period := time.Duration(options.period) * time.Second; -options.lookback < period {
+    return fmt.Errorf("%w: lookback %s is shorter than the %s period, so no bucket fits in the window; use a longer -lookback or a shorter -period", ErrInvalidWindow, -options.lookback, period)
+  }
+
+  start, end := options.window(time.Now())
+  panels, err := client.fetchPanels(options, start, end)
+  if err != nil {
+    return err
+  }
+  panels, namespace := transformPanels(options, panels, end), options.namespace
+  if redact {
+    panels, namespace = redactPanels(panels, namespace)
+  }
+  graph := stripEscapes(safePlotPanels(panels, namespace, options.lookback, end, defaultServeWidth, defaultServeHeight))
+
+  if err := os.MkdirAll(options.snapshot, 0755); err != nil {
+    return fmt.Errorf("cannot create -snapshot directory: %w", err)
+  }
+  prefix := snapshotPrefix(options.queries)
+  path := filepath.Join(options.snapshot, prefix + "-" + end.UTC().Format(snapshotTimeLayout) + ".txt")
+  // Written aside and renamed so a failed run never leaves a half-written snapshot behind to be diffed
+  temporary := path + ".tmp"
+  if err := os.WriteFile(temporary, []byte(graph + "\n"), 0644); err != nil {
+    return fmt.Errorf("cannot write -snapshot: %w", err)
+  }
+  if err := os.Rename(temporary, path); err != nil {
+    os.Remove(temporary)
+    return fmt.Errorf("cannot write -snapshot: %w", err)
+  }
+  if options.info {
+    infof("Wrote %s", path)
+  }
+
+  if options.snapshotKeep > 0 {
+    return rotateSnapshots(options.snapshot, prefix, options.snapshotKeep)
+  }
+
+  return nil
+}
+
+// The distinct metric names joined by +, so snapshots of -group-by panels share one name while different metrics in the same directory don't rotate each other away
+func snapshotPrefix(queries []MetricQuery) string {
+  names := []string{}
+  seen := map[string]bool{}
+  for _, query := range queries {
+    if !seen[query.Metric] {
+      seen[query.Metric] = true
+      names = append(names, unsafeSnapshotChars.ReplaceAllString(query.Metric, "_"))
+    }
+  }
+
+  return strings.Join(names, "+")
+}
+
+// Delete all but the newest keep snapshots with the prefix. Only names in exactly the snapshot format are touched, so other files in the directory are safe.
+func rotateSnapshots(dir string, prefix string, keep int) error {
+  entries, err := os.ReadDir(dir)
+  if err != nil {
+    return fmt.Errorf("cannot rotate -snapshot: %w", err)
+  }
+
+  snapshots := []string{}
+  for _, entry := range entries {
+    name := entry.Name()
+    if entry.IsDir() || !strings.HasPrefix(name, prefix + "-") || !strings.HasSuffix(name, ".txt") {
+      continue
+    }
+    if _, err := time.Parse(snapshotTimeLayout, strings.TrimSuffix(strings.TrimPrefix(name, prefix + "-"), ".txt")); err != nil {
+      continue
+    }
+    snapshots = append(snapshots, name)
+  }
+  sort.Strings(snapshots)
+
+  for len(snapshots) > keep {
+    if err := os.Remove(filepath.Join(dir, snapshots[0])); err != nil {
+      return fmt.Errorf("cannot rotate -snapshot: %w", err)
+    }
+    snapshots = snapshots[1:]
+  }
+
+  return nil
+}
+
+// The text without terminal escapes, e.g. the colors of the graph and the sequence clearing the screen before it, which would only be noise in a diff or a ticket
+func stripEscapes(text string) string {
+  var stripped strings.Builder
+  escaped, sequence := false, false
+  for _, r := range text {
+    switch {
+    case sequence:
+      // A control sequence runs to its final byte, e.g. the m of a color or the J of a clear
+      sequence = r < '@' || r > '~'
+      continue
+    case escaped:
+      escaped, sequence = false, r == '['
+      continue
+    case r == '\x1b':
+      escaped = true
+      continue
+    }
+    stripped.WriteRune(r)
+  }
+
+  return stripped.String()
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "net"
+  "os"
+  "sync"
+  "time"
+)
+
+// How long a socket client may take to accept a datapoint before it's dropped, so a stalled frontend can't hold up the fetch loop
+const socketWriteTimeout = 5 * time.Second
+
+// The frontends currently connected to -socket
+type socketClients struct {
+  mutex sync.Mutex
+  connections map[net.Conn]bool
+}
+
+func (clients *socketClients) add(connection net.Conn) {
+  clients.mutex.Lock()
+  defer clients.mutex.Unlock()
+  clients.connections[connection] = true
+}
+
+// Write the line to every client, dropping any that have gone away
+func (clients *socketClients) broadcast(line []byte) {
+  clients.mutex.Lock()
+  defer clients.mutex.Unlock()
+  for connection := range clients.connections {
+    connection.SetWriteDeadline(time.Now().Add(socketWriteTimeout))
+    if _, err := connection.Write(line); err != nil {
+      warnf("Socket client disconnected: %s", err.Error())
+      connection.Close()
+      delete(clients.connections, connection)
+    }
+  }
+}
+
+// Tail the metrics, writing each new datapoint as a JSON line to every client connected to the Unix socket at -socket
+func (client Client) streamSocket(options Options) error {
+  // A socket left behind by a previous run that was killed would make Listen fail
+  if info, err := os.Stat(options.socket); err == nil && info.Mode() & os.ModeSocket != 0 {
+    os.Remove(options.socket)
+  }
+  listener, err := net.Listen("unix", options.socket)
+  if err != nil {
+    return fmt.Errorf("cannot listen on -socket %s: %w", options.socket, err)
+  }
+  defer listener.Close()
+
+  clients := &socketClients{ connections: map[net.Conn]bool{} }
+  go func () {
+    for {
+      connection, err := listener.Accept()
+      if err != nil {
+        return
+      }
+      clients.add(connection)
+    }
+  }()
+
+  return client.streamDatapoints(options, func (panel Panel, point Point) error {
+    line, err := json.Marshal(jsonDatapoint{ Metric: panel.Label, Timestamp: point.Timestamp, Value: point.Value })
+    if err != nil {
+      return err
+    }
+    clients.broadcast(append(line, '\n'))
+    return nil
+  })
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+  "math"
+  "strings"
+)
+
+var sparkTicks = []rune("▁▂▃▄▅▆▇█")
+
+// Squeeze the values into a one-line chart of at most width characters, averaging neighbouring values together. Breaks are drawn as spaces.
+func sparkline(values []float64, width int) string {
+  if len(values) > width {
+    values = downsample(values, width)
+  }
+
+  min, max := math.Inf(1), math.Inf(-1)
+  for _, value := range values {
+    if !math.IsNaN(value) {
+      min, max = math.Min(min, value), math.Max(max, value)
+    }
+  }
+
+  var builder strings.Builder
+  for _, value := range values {
+    switch {
+    case math.IsNaN(value):
+      builder.WriteRune(' ')
+    case max == min:
+      builder.WriteRune(sparkTicks[0])
+    default:
+      builder.WriteRune(sparkTicks[int((value - min) / (max - min) * float64(len(sparkTicks) - 1))])
+    }
+  }
+
+  return builder.String()
+}
+
+func downsample(values []float64, width int) []float64 {
+  downsampled := make([]float64, width)
+  for i := range downsampled {
+    from, to := i * len(values) / width, (i + 1) * len(values) / width
+    total, count := 0.0, 0
+    for _, value := range values[from:to] {
+      if !math.IsNaN(value) {
+        total += value
+        count++
+      }
+    }
+
+    downsampled[i] = math.NaN()
+    if count > 0 {
+      downsampled[i] = total / float64(count)
+    }
+  }
+
+  return downsampled
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+  "fmt"
+  "path"
+  "strings"
+
+  "github.com/aws/aws-sdk-go/aws/awserr"
+  "github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// The CloudFormation resource type whose metrics live in a namespace, and the dimension identifying a resource there
+type stackResourceType struct {
+  resourceType string
+  dimension string
+}
+
+var stackResourceTypes = map[string]stackResourceType{
+  "AWS/Lambda": { resourceType: "AWS::Lambda::Function", dimension: "FunctionName" },
+  "AWS/DynamoDB": { resourceType: "AWS::DynamoDB::Table", dimension: "TableName" },
+  "AWS/SQS": { resourceType: "AWS::SQS::Queue", dimension: "QueueName" },
+}
+
+// Replace the queries with one per metric per resource in the stack that publishes to the namespace
+func (client Client) expandStackQueries(options Options) (Options, error) {
+  resourceType, ok := stackResourceTypes[options.namespace]
+  if !ok {
+    supported := []string{}
+    for namespace := range stackResourceTypes {
+      supported = append(supported, namespace)
+    }
+    return options, fmt.Errorf("-stack doesn't know which resources publish to %s (supported: %s)", options.namespace, strings.Join(supported, ", "))
+  }
+
+  names := []string{}
+  input := cloudformation.ListStackResourcesInput{ StackName: &options.stack }
+  err := cloudformation.New(client.session).ListStackResourcesPages(&input, func (page *cloudformation.ListStackResourcesOutput, lastPage bool) bool {
+    for _, resource := range page.StackResourceSummaries {
+      if *resource.ResourceType == resourceType.resourceType && resource.PhysicalResourceId != nil {
+        // Queues are identified by URL, the rest by name; the last path segment covers both
+        names = append(names, path.Base(*resource.PhysicalResourceId))
+      }
+    }
+    return true
+  })
+  if err != nil {
+    if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "ValidationError" {
+      return options, fmt.Errorf("stack %s not found: %s", options.stack, awsErr.Message())
+    }
+    return options, classifyAWSError(err)
+  }
+  if len(names) == 0 {
+    return options, fmt.Errorf("stack %s has no %s resources", options.stack, resourceType.resourceType)
+  }
+
+  queries := []MetricQuery{}
+  for _, query := range options.queries {
+    for _, name := range names {
+      resourceQuery := query
+      resourceQuery.Dimensions = withDimension(query.Dimensions, resourceType.dimension, name)
+      queries = append(queries, resourceQuery)
+    }
+  }
+  options.queries = queries
+  options.stacked = true
+
+  return options, nil
+}
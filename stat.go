@@ -0,0 +1,78 @@
+package main
+
+import (
+  "math"
+  "regexp"
+
+  "github.com/aws/aws-sdk-go/service/cloudwatch"
+)
+
+// Values accepted by -derive
+const deriveAverage = "average"
+
+// Percentiles go in ExtendedStatistics rather than Statistics, e.g. p99 or p99.9
+var percentilePattern = regexp.MustCompile(`^p(\d{1,2}(\.\d{1,2})?|100)$`)
+
+func validStat(stat string) bool {
+  for _, known := range cloudwatch.Statistic_Values() {
+    if stat == known {
+      return true
+    }
+  }
+
+  return percentilePattern.MatchString(stat)
+}
+
+// Ask for the query's statistic. A derived average asks for SampleCount and Sum together and divides them, as is usual for metrics published as StatisticSets.
+func setRequestStatistics(request *cloudwatch.GetMetricStatisticsInput, query MetricQuery) {
+  if percentilePattern.MatchString(query.Stat) {
+    stat := query.Stat
+    request.ExtendedStatistics = []*string{ &stat }
+    return
+  }
+
+  stat := query.Stat
+  request.Statistics = []*string{ &stat }
+  if query.Derive == deriveAverage {
+    sum := cloudwatch.StatisticSum
+    request.Statistics = append(request.Statistics, &sum)
+  }
+}
+
+// The value a datapoint stands for under the request's statistics, see setRequestStatistics
+func datapointValue(request *cloudwatch.GetMetricStatisticsInput, datapoint *cloudwatch.Datapoint) float64 {
+  if len(request.ExtendedStatistics) > 0 {
+    value, ok := datapoint.ExtendedStatistics[*request.ExtendedStatistics[0]]
+    if !ok || value == nil {
+      return math.NaN()
+    }
+    return *value
+  }
+
+  switch *request.Statistics[0] {
+  case cloudwatch.StatisticSampleCount:
+    if len(request.Statistics) > 1 && *request.Statistics[1] == cloudwatch.StatisticSum {
+      return *datapoint.Sum / *datapoint.SampleCount
+    }
+    return *datapoint.SampleCount
+  case cloudwatch.StatisticSum:
+    return *datapoint.Sum
+  case cloudwatch.StatisticAverage:
+    return *datapoint.Average
+  case cloudwatch.StatisticMinimum:
+    return *datapoint.Minimum
+  default:
+    return *datapoint.Maximum
+  }
+}
+
+// With -min-samples, an Average request also asks for SampleCount so buckets averaging too few samples can be told apart
+func requestSampleCounts(request *cloudwatch.GetMetricStatisticsInput) bool {
+  if len(request.Statistics) != 1 || *request.Statistics[0] != cloudwatch.StatisticAverage {
+    return false
+  }
+  sampleCount := cloudwatch.StatisticSampleCount
+  request.Statistics = append(request.Statistics, &sampleCount)
+
+  return true
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+  "fmt"
+  "os"
+  "strings"
+  "sync"
+  "time"
+  "unicode/utf8"
+
+  "github.com/guptarohit/asciigraph"
+  "golang.org/x/crypto/ssh/terminal"
+)
+
+// Keys that step the window, as read from a raw terminal. Arrow keys arrive as escape sequences.
+var (
+  stepBackKeys = []string{ "\x1b[D", "h", "[" }
+  stepForwardKeys = []string{ "\x1b[C", "l", "]" }
+  stepLatestKeys = []string{ "\x1b[F", "\x1bOF", "\x1b[4~", "$" }
+  stepRetryKeys = []string{ "r" }
+  // Ctrl-C doesn't raise SIGINT in raw mode, so it's read like any other key
+  stepQuitKeys = []string{ "q", "\x03", "\x1b" }
+)
+
+// A window fetched, or being fetched, for -window-step, keyed by its end
+type steppedWindow struct {
+  done chan struct{}
+  panels []Panel
+  err error
+}
+
+// Fetched windows by end, with each neighbour of the one shown fetched in the background so a step is usually instant
+type stepCache struct {
+  client Client
+  options Options
+  mutex sync.Mutex
+  windows map[time.Time]*steppedWindow
+}
+
+// Page through history a -window-step at a time with the arrow keys, each page a -lookback long, for going over an incident window by window.
+// Stepping is bounded by the latest window, since the one after it hasn't happened yet.
+func (client Client) renderStepped(options Options) error {
+  fd := int(os.Stdin.Fd())
+  if !terminal.IsTerminal(fd) {
+    return fmt.Errorf("-window-step reads keys, so it needs an interactive terminal")
+  }
+  state, err := terminal.MakeRaw(fd)
+  if err != nil {
+    return fmt.Errorf("cannot read keys for -window-step: %w", err)
+  }
+  defer terminal.Restore(fd, state)
+
+  keys := make(chan string)
+  go readKeys(keys)
+  resized := make(chan os.Signal, 1)
+  notifyOnResize(resized)
+
+  cache := &stepCache{ client: client, options: options, windows: map[time.Time]*steppedWindow{} }
+  // Without the monotonic reading, so the same window stepped to twice is the same cache key
+  latest := options.windowEnd(time.Now()).Round(0)
+  end := latest
+  for {
+    window := cache.get(end)
+    <-window.done
+    cache.get(end.Add(-options.windowStep))
+    if end.Before(latest) {
+      cache.get(end.Add(options.windowStep))
+    }
+    // A failed window was dropped from the cache, so it's shown until a retry or step fetches it again
+    if window.err != nil {
+      drawSteppedError(options, end, window.err)
+    } else if err := drawStepped(options, window.panels, end); err != nil {
+      return err
+    }
+
+    for redraw := false; !redraw; {
+      select {
+      case <-resized:
+        redraw = true
+      case key := <-keys:
+        switch {
+        case isKey(key, stepQuitKeys):
+          return nil
+        case isKey(key, stepBackKeys):
+          end, redraw = end.Add(-options.windowStep), true
+        case isKey(key, stepForwardKeys) && end.Before(latest):
+          end, redraw = end.Add(options.windowStep), true
+        case isKey(key, stepLatestKeys) && end.Before(latest):
+          end, redraw = latest, true
+        case isKey(key, stepRetryKeys) && window.err != nil:
+          redraw = true
+        }
+      }
+    }
+  }
+}
+
+// The window ending at end, starting its fetch if it isn't cached. A failed fetch is dropped from the cache so stepping back to it retries.
+func (cache *stepCache) get(end time.Time) *steppedWindow {
+  cache.mutex.Lock()
+  defer cache.mutex.Unlock()
+  if window, ok := cache.windows[end]; ok {
+    return window
+  }
+
+  window := &steppedWindow{ done: make(chan struct{}) }
+  cache.windows[end] = window
+  go func () {
+    defer close(window.done)
+    start := cache.options.align.snap(end.Add(cache.options.lookback), time.Duration(cache.options.period) * time.Second)
+    window.panels, window.err = cache.client.fetchPanels(cache.options, start, end)
+    if window.err != nil {
+      cache.mutex.Lock()
+      delete(cache.windows, end)
+      cache.mutex.Unlock()
+    }
+  }()
+
+  return window
+}
+
+// Draw the window with its absolute bounds in the caption and the keys under it. The terminal is raw, so each line needs its own carriage return.
+func drawStepped(options Options, panels []Panel, end time.Time) error {
+  width, height, err := terminal.GetSize(int(os.Stdin.Fd()))
+  if err != nil {
+    return err
+  }
+
+  panels, namespace := transformPanels(options, panels, end), options.namespace
+  for i := range panels {
+    panels[i].WindowStart = end.Add(options.lookback)
+  }
+  if redact {
+    panels, namespace = redactPanels(panels, namespace)
+  }
+  graph := safePlotPanels(panels, namespace, options.lookback, end, width, height - 1)
+  asciigraph.Clear()
+  fmt.Print(strings.ReplaceAll(graph, "\n", "\r\n") + "\r\n" + steppedHelp(options))
+
+  return nil
+}
+
+// Draw a window whose fetch failed as a caption with its bounds and the error, and the keys under it
+func drawSteppedError(options Options, end time.Time, err error) {
+  caption := fmt.Sprintf("%s to %s: %v", formatTime(end.Add(options.lookback)), formatTime(end), err)
+  asciigraph.Clear()
+  fmt.Print(strings.ReplaceAll(caption, "\n", "\r\n") + "\r\n" + steppedHelp(options) + ", r to retry")
+}
+
+func steppedHelp(options Options) string {
+  return fmt.Sprintf("←/h back or →/l forward %s, End/$ to the latest, q to quit", options.windowStep)
+}
+
+// Send each key read from stdin, with escape sequences whole. Pasting or holding a key can deliver several in one read.
+func readKeys(keys chan<- string) {
+  buffer := make([]byte, 64)
+  pending := ""
+  for {
+    n, err := os.Stdin.Read(buffer)
+    if err != nil {
+      keys <- "q"
+      return
+    }
+    split, rest := splitKeys(pending + string(buffer[:n]))
+    for _, key := range split {
+      keys <- key
+    }
+    // A sequence cut off by a full buffer is finished by the next read, but one cut off anywhere else never will be
+    pending = ""
+    if n == len(buffer) {
+      pending = rest
+    } else if rest != "" {
+      keys <- rest
+    }
+  }
+}
+
+// Split input from a raw terminal into keys, with escape sequences whole and everything else a character at a time. An escape sequence cut off at the end is returned as rest.
+func splitKeys(input string) ([]string, string) {
+  keys := []string{}
+  for input != "" {
+    length := keyLength(input)
+    if length == 0 {
+      return keys, input
+    }
+    keys, input = append(keys, input[:length]), input[length:]
+  }
+
+  return keys, ""
+}
+
+// The length in bytes of the key input starts with, or 0 for an escape sequence that's cut off
+func keyLength(input string) int {
+  if input[0] != '\x1b' {
+    _, size := utf8.DecodeRuneInString(input)
+    return size
+  }
+  if len(input) == 1 {
+    // Escape on its own
+    return 1
+  }
+
+  switch input[1] {
+  case '[':
+    // Parameters, then a final byte from @ to ~, e.g. \x1b[D or \x1b[4~
+    for i := 2; i < len(input); i++ {
+      if input[i] >= '@' && input[i] <= '~' {
+        return i + 1
+      }
+    }
+    return 0
+  case 'O':
+    if len(input) < 3 {
+      return 0
+    }
+    return 3
+  }
+
+  // Escape followed by another key
+  return 1
+}
+
+func isKey(key string, candidates []string) bool {
+  for _, candidate := range candidates {
+    if key == candidate {
+      return true
+    }
+  }
+
+  return false
+}
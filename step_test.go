@@ -0,0 +1,33 @@
+package main
+
+import (
+  "reflect"
+  "testing"
+)
+
+func TestSplitKeys(t *testing.T) {
+  cases := []struct {
+    input string
+    keys []string
+    rest string
+  }{
+    { input: "h", keys: []string{ "h" } },
+    { input: "hhl", keys: []string{ "h", "h", "l" } },
+    { input: "\x1b[D\x1b[D\x1b[C", keys: []string{ "\x1b[D", "\x1b[D", "\x1b[C" } },
+    { input: "[\x1b[4~$", keys: []string{ "[", "\x1b[4~", "$" } },
+    { input: "\x1bOFq", keys: []string{ "\x1bOF", "q" } },
+    { input: "\x1b", keys: []string{ "\x1b" } },
+    { input: "\x1bq", keys: []string{ "\x1b", "q" } },
+    { input: "→h", keys: []string{ "→", "h" } },
+    { input: "h\x1b[", keys: []string{ "h" }, rest: "\x1b[" },
+    { input: "\x1b[4", keys: []string{}, rest: "\x1b[4" },
+    { input: "\x1bO", keys: []string{}, rest: "\x1bO" },
+  }
+
+  for _, c := range cases {
+    keys, rest := splitKeys(c.input)
+    if !reflect.DeepEqual(keys, c.keys) || rest != c.rest {
+      t.Errorf("splitKeys(%q) = %q, %q, want %q, %q", c.input, keys, rest, c.keys, c.rest)
+    }
+  }
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+  "fmt"
+  "math"
+  "strconv"
+  "time"
+)
+
+// Lines printed under a panel's graph
+func summarize(options Options, panel Panel, end time.Time) []string {
+  footer := []string{}
+  if !options.deployedAt.IsZero() {
+    footer = append(footer, deploySummary(options.deployedAt, end))
+  }
+  if options.compareMetrics && len(panel.Overlays) == 1 {
+    footer = append(footer, correlationSummary(panel))
+  }
+  if options.threshold.set {
+    footer = append(footer, thresholdSummary(panel.Series, options.threshold.value, options.thresholdBelow, options.thresholdIncludeFilled))
+  }
+
+  return footer
+}
+
+// What fraction of the window breached the threshold. Gap-filled buckets weren't measured, so by default they count towards neither side.
+func thresholdSummary(series Series, threshold float64, below bool, includeFilled bool) string {
+  breaching, total := 0, 0
+  for _, point := range series {
+    if math.IsNaN(point.Value) || (point.Filled && !includeFilled) {
+      continue
+    }
+    total++
+    if (below && point.Value < threshold) || (!below && point.Value > threshold) {
+      breaching++
+    }
+  }
+
+  direction := "above"
+  if below {
+    direction = "below"
+  }
+  if total == 0 {
+    return fmt.Sprintf("No datapoints to compare against threshold %s", strconv.FormatFloat(threshold, 'f', -1, 64))
+  }
+
+  return fmt.Sprintf("%.1f%% of datapoints %s %s (%d of %d)", 100 * float64(breaching) / float64(total), direction, strconv.FormatFloat(threshold, 'f', -1, 64), breaching, total)
+}
+
+// How many buckets -min-samples hid
+func suppressedSummary(series Series, minSamples float64) string {
+  suppressed := 0
+  for _, point := range series {
+    if point.Suppressed {
+      suppressed++
+    }
+  }
+
+  return fmt.Sprintf("%d of %d bucket(s) hidden for averaging fewer than %g samples", suppressed, len(series), minSamples)
+}
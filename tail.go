@@ -0,0 +1,135 @@
+package main
+
+import (
+  "errors"
+  "math"
+  "os"
+  "time"
+)
+
+// Shortest gap between polls of a feed, so high-resolution metrics don't burn through the API quota
+const minTailInterval = 10 * time.Second
+
+// Some of the displayed panels, polled on their own schedule while tailing
+type tailFeed struct {
+  // Indices of the panels this feed slides forward
+  panels []int
+  // The feed's period, which plain -align snaps its window to
+  period time.Duration
+  interval time.Duration
+  fetch func (start time.Time, end time.Time) ([]Panel, error)
+}
+
+// Buckets a feed fetched up to end, in the order of its panels
+type tailUpdate struct {
+  feed int
+  panels []Panel
+  end time.Time
+  err error
+}
+
+// The feeds to tail. Each dashboard panel is polled at its own period so a slow metric isn't fetched more often than it publishes. Everything else shares -period, so it's polled as one.
+func (client Client) tailFeeds(options Options, count int) []tailFeed {
+  if len(options.dashboardPanels) == 0 {
+    all := []int{}
+    for i := 0; i < count; i++ {
+      all = append(all, i)
+    }
+
+    period := time.Duration(options.period) * time.Second
+    return []tailFeed{{ panels: all, period: period, interval: tailInterval(period), fetch: func (start time.Time, end time.Time) ([]Panel, error) {
+      return client.fetchPanels(options, start, end)
+    }}}
+  }
+
+  feeds := []tailFeed{}
+  for i, dashboard := range options.dashboardPanels {
+    dashboard := dashboard
+    period := time.Duration(dashboard.period()) * time.Second
+    feeds = append(feeds, tailFeed{ panels: []int{ i }, period: period, interval: tailInterval(period), fetch: func (start time.Time, end time.Time) ([]Panel, error) {
+      panel, err := client.fetchDashboardPanel(options, dashboard, start, end)
+      return []Panel{ panel }, err
+    }})
+  }
+
+  return feeds
+}
+
+func tailInterval(period time.Duration) time.Duration {
+  if period < minTailInterval {
+    return minTailInterval
+  }
+
+  return period
+}
+
+// How many buckets each of the feed's panels keeps: -tail-history-limit, or as many as fit in the window
+func (feed tailFeed) historyLimit(options Options) int {
+  if options.tailHistoryLimit > 0 {
+    return options.tailHistoryLimit
+  }
+
+  return int(math.Ceil(float64(-options.lookback) / float64(feed.period)))
+}
+
+// Poll the feed from end until it fails, sending each new batch of buckets
+func (feed tailFeed) poll(options Options, index int, end time.Time, updates chan<- tailUpdate) {
+  for {
+    time.Sleep(feed.interval)
+
+    // If nothing new was published, retry the same window on the next poll rather than dropping it
+    newEnd := options.align.snap(time.Now(), feed.period)
+    if !newEnd.After(end) {
+      continue
+    }
+    panels, err := feed.fetch(end, newEnd)
+    if errors.Is(err, ErrNoData) {
+      continue
+    }
+    updates <- tailUpdate{ feed: index, panels: panels, end: newEnd, err: err }
+    if err != nil {
+      return
+    }
+    end = newEnd
+  }
+}
+
+// Keep the panels fetched up to end current, polling each feed independently and redrawing all of them whenever one updates
+func (client Client) tail(options Options, panels []Panel, end time.Time) error {
+  feeds := client.tailFeeds(options, len(panels))
+  updates := make(chan tailUpdate)
+  for i, feed := range feeds {
+    go feed.poll(options, i, end, updates)
+  }
+
+  resized := make(chan os.Signal, 1)
+  notifyOnResize(resized)
+  for {
+    updated := []int{}
+    // Redraw the cached panels at the new size right away instead of waiting for the next poll
+    select {
+    case <-resized:
+    case update := <-updates:
+      if update.err != nil {
+        return update.err
+      }
+      updated = feeds[update.feed].panels
+      for i, panel := range updated {
+        slidePanels(panels[panel:panel + 1], update.panels[i:i + 1], feeds[update.feed].historyLimit(options))
+      }
+      if update.end.After(end) {
+        end = update.end
+      }
+    }
+
+    if err := render(transformPanels(options, panels, end), options.namespace, options.lookback, end); err != nil {
+      return err
+    }
+    // After rendering, which clears the screen, so the command's output stays visible until the next poll
+    if options.onUpdate != "" {
+      for _, panel := range updated {
+        runOnUpdate(options, panels[panel])
+      }
+    }
+  }
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+  "time"
+)
+
+// Named layouts accepted by -time-format. Anything else is taken as a Go layout.
+var timeFormatPresets = map[string]string{
+  "kitchen": time.Kitchen,
+  "rfc3339": time.RFC3339,
+  "rfc1123": time.RFC1123,
+  "datetime": "2006-01-02 15:04:05",
+  "time": "15:04:05",
+}
+
+// Set by -time-format, for timestamps shown to people. Machine-readable outputs keep their own formats.
+var timeLayout = time.RFC3339
+
+func formatTime(t time.Time) string {
+  if redact {
+    return redactedTime
+  }
+  // Round(0) drops the monotonic clock reading, which String() would otherwise print
+  return t.Round(0).Format(timeLayout)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+  "errors"
+  "fmt"
+  "os"
+  "sort"
+  "strconv"
+  "time"
+
+  "github.com/guptarohit/asciigraph"
+  "golang.org/x/crypto/ssh/terminal"
+)
+
+// Values accepted by -top-by
+const (
+  topByLatest = "latest"
+  topByMax = "max"
+)
+
+const topSparklineWidth = 20
+
+type topRow struct {
+  label string
+  value float64
+  series Series
+}
+
+// Rank every series matching the queries by -top-by, like `top` does for processes.
+// The queries are expanded again on every refresh, so a tailed -top picks up series that start reporting and drops deleted ones. With -discovery-cache-ttl that is a cache read rather than a ListMetrics call.
+func (client Client) renderTop(options Options) error {
+  for {
+    queries, err := client.expandQueries(options.queries)
+    if err != nil {
+      return err
+    }
+    if len(queries) == 0 {
+      return fmt.Errorf("%w: no metrics in %s match %s", ErrNoData, options.namespace, options.metrics)
+    }
+
+    end := time.Now()
+    rows, err := client.fetchTopRows(options, queries, end.Add(options.lookback), end)
+    if err != nil {
+      return err
+    }
+
+    if options.tail {
+      asciigraph.Clear()
+    }
+    printTop(options, rows, end)
+    if !options.tail {
+      return nil
+    }
+    time.Sleep(tailInterval(time.Duration(options.period) * time.Second))
+  }
+}
+
+func (client Client) fetchTopRows(options Options, queries []MetricQuery, start time.Time, end time.Time) ([]topRow, error) {
+  rows := []topRow{}
+  for _, query := range queries {
+    request := newMetricStatisticsRequest(query, start, end)
+    series, err := client.getMetricSampleCounts(&request, options.fillPolicy())
+    if errors.Is(err, ErrNoData) && !options.failOnEmpty {
+      continue
+    }
+    if err == nil && options.failOnEmpty {
+      err = requireData(query.Label(), series, start, end)
+    }
+    if err != nil {
+      return rows, err
+    }
+
+    row := topRow{ label: query.Label(), series: series }
+    if options.topBy == topByMax {
+      _, row.value, _ = series.Bounds()
+    } else {
+      latest, _ := series.Last()
+      row.value = latest.Value
+    }
+    rows = append(rows, row)
+  }
+
+  sort.SliceStable(rows, func (i, j int) bool {
+    return rows[i].value > rows[j].value
+  })
+
+  return rows, nil
+}
+
+// As many of the rows as fit in the terminal under a header line, leaving the last line for the cursor. When stdout isn't a terminal there's nothing to fit.
+func fitRows(rows []topRow) []topRow {
+  fd := int(os.Stdout.Fd())
+  if !terminal.IsTerminal(fd) {
+    return rows
+  }
+  if _, height, err := terminal.GetSize(fd); err == nil && len(rows) > height - 2 && height > 2 {
+    return rows[:height - 2]
+  }
+
+  return rows
+}
+
+func printTop(options Options, rows []topRow, end time.Time) {
+  rows = fitRows(rows)
+
+  fmt.Printf("[%s] top %d by %s with lookback=%s (last updated at %s)\n", options.namespace, len(rows), options.topBy, options.lookback, formatTime(end))
+  for i, row := range rows {
+    fmt.Printf("%3d  %12s  %-*s  %s\n", i + 1, strconv.FormatFloat(row.value, 'f', -1, 64), topSparklineWidth, sparkline(row.series.Values(), topSparklineWidth), row.label)
+  }
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+  "fmt"
+  "math"
+  "time"
+)
+
+// Values accepted by -display-aggregate and -resample-aggregate. Values are in time order.
+var aggregations = map[string]func (values []float64) float64{
+  "avg": func (values []float64) float64 {
+    return sum(values) / float64(len(values))
+  },
+  "sum": sum,
+  "max": func (values []float64) float64 {
+    max := math.Inf(-1)
+    for _, value := range values {
+      max = math.Max(max, value)
+    }
+    return max
+  },
+  "last": func (values []float64) float64 {
+    return values[len(values) - 1]
+  },
+}
+
+const aggregationNames = "avg, sum, max, or last"
+
+func sum(values []float64) float64 {
+  total := 0.0
+  for _, value := range values {
+    total += value
+  }
+
+  return total
+}
+
+// Display-only transforms, applied to a copy of the fetched panels just before drawing so tail mode keeps sliding the raw buckets
+func transformPanels(options Options, panels []Panel, end time.Time) []Panel {
+  transformed := make([]Panel, len(panels))
+  for i, panel := range panels {
+    if baseline, ok := options.baseline[panel.Label]; ok {
+      panel.Overlays = append(append([]Panel{}, panel.Overlays...), baselineOverlay(panel, baseline))
+    }
+    transformed[i] = transformPanel(options, panel, end)
+    if options.compactGaps > 0 {
+      transformed[i] = compactPanel(transformed[i], options.compactGaps)
+    }
+    transformed[i].Footer = summarize(options, transformed[i], end)
+    // Counted before re-bucketing, which merges suppressed buckets away
+    if options.minSamples > 0 {
+      transformed[i].Footer = append(transformed[i].Footer, suppressedSummary(panel.Series, options.minSamples))
+    }
+    if options.highlightZScore > 0 {
+      transformed[i] = highlightAnomalies(transformed[i], options.highlightZScore, options.zscoreWindow)
+    }
+    // After summarizing, so the footer still reports real values
+    if options.normalize != "" {
+      transformed[i] = normalizePanel(transformed[i], options.normalize)
+    }
+    if options.dualAxis {
+      transformed[i] = dualAxisPanel(transformed[i])
+    }
+    transformed[i].YMin, transformed[i].YMax = options.yMin, options.yMax
+    transformed[i].CaptionTemplate = options.captionTemplate
+    transformed[i].Events = append(append([]Event{}, options.events...), transformed[i].Events...)
+  }
+  // Compacted gaps deliberately give up a linear time axis, so there's nothing to line up, and -multi-lookback panels cover different windows on purpose
+  if len(transformed) > 1 && options.compactGaps == 0 && len(options.multiLookback) == 0 {
+    alignPanels(transformed, time.Duration(options.period) * time.Second)
+  }
+
+  return transformed
+}
+
+// Pad each stacked panel with breaks so all of them span the same time range, e.g. when one metric started publishing late or was trimmed by -trim-zeros.
+// Every panel is stretched across the full width, so otherwise the same column would be a different time in each one. The y-axes stay independent.
+func alignPanels(panels []Panel, period time.Duration) {
+  var first, last time.Time
+  for _, panel := range panels {
+    if len(panel.Series) == 0 {
+      continue
+    }
+    if start := panel.Series[0].Timestamp; first.IsZero() || start.Before(first) {
+      first = start
+    }
+    if end := panel.Series[len(panel.Series) - 1].Timestamp; end.After(last) {
+      last = end
+    }
+  }
+
+  for i, panel := range panels {
+    if len(panel.Series) == 0 {
+      continue
+    }
+    step := panel.Series.step(period)
+    before := int(panel.Series[0].Timestamp.Sub(first) / step)
+    after := int(last.Sub(panel.Series[len(panel.Series) - 1].Timestamp) / step)
+    if before == 0 && after == 0 {
+      continue
+    }
+    // Overlays are drawn against the panel's columns, so they're padded by the same amounts
+    padded := panel
+    padded.Series = panel.Series.pad(before, after, step)
+    padded.Overlays = make([]Panel, len(panel.Overlays))
+    for j, overlay := range panel.Overlays {
+      padded.Overlays[j] = overlay
+      padded.Overlays[j].Series = overlay.Series.pad(before, after, step)
+    }
+    panels[i] = padded
+  }
+}
+
+// The width of the series' buckets, which may differ from -period after re-bucketing or in a dashboard panel
+func (series Series) step(period time.Duration) time.Duration {
+  if len(series) > 1 && series[1].Timestamp.After(series[0].Timestamp) {
+    return series[1].Timestamp.Sub(series[0].Timestamp)
+  }
+
+  return period
+}
+
+// The series with before breaks ahead of its first bucket and after breaks past its last, step apart
+func (series Series) pad(before int, after int, step time.Duration) Series {
+  if len(series) == 0 {
+    return series
+  }
+
+  padded := make(Series, 0, before + len(series) + after)
+  for j := before; j > 0; j-- {
+    padded = append(padded, Point{ Timestamp: series[0].Timestamp.Add(-time.Duration(j) * step), Value: math.NaN(), Filled: true })
+  }
+  padded = append(padded, series...)
+  for j := 1; j <= after; j++ {
+    padded = append(padded, Point{ Timestamp: series[len(series) - 1].Timestamp.Add(time.Duration(j) * step), Value: math.NaN(), Filled: true })
+  }
+
+  return padded
+}
+
+func transformPanel(options Options, panel Panel, end time.Time) Panel {
+  if options.dropLast {
+    panel.Series = panel.Series.DropPartial(time.Duration(options.period) * time.Second, end)
+  }
+  if options.resampleTo > 0 {
+    panel.Series = panel.Series.Resample(options.resampleTo, aggregations[options.resampleAggregate], options.fillPolicy())
+  }
+  if options.displayPeriod > 0 {
+    panel.Series = panel.Series.Rebucket(options.displayPeriod, aggregations[options.displayAggregate])
+  }
+
+  overlays := make([]Panel, len(panel.Overlays))
+  for i, overlay := range panel.Overlays {
+    overlays[i] = transformPanel(options, overlay, end)
+  }
+  panel.Overlays = overlays
+
+  return panel
+}
+
+// Group points into buckets of the given width and aggregate each. Breaks are ignored, and a bucket holding only breaks stays a break.
+func (series Series) Rebucket(width time.Duration, aggregate func (values []float64) float64) Series {
+  rebucketed := Series{}
+  for i := 0; i < len(series); {
+    bucket := series[i].Timestamp.Truncate(width)
+    point := Point{ Timestamp: bucket, Value: math.NaN(), Filled: true }
+    values := []float64{}
+    for ; i < len(series) && series[i].Timestamp.Truncate(width).Equal(bucket); i++ {
+      if !math.IsNaN(series[i].Value) {
+        values = append(values, series[i].Value)
+      }
+      point.Filled = point.Filled && series[i].Filled
+    }
+
+    if len(values) > 0 {
+      point.Value = aggregate(values)
+    }
+    rebucketed = append(rebucketed, point)
+  }
+
+  return rebucketed
+}
+
+// Re-bucket only the datapoints CloudWatch returned into fixed intervals, then fill the gaps at that interval. Unlike Rebucket this ignores the gap-fill at the fetch period, which misrepresents metrics published irregularly.
+func (series Series) Resample(width time.Duration, aggregate func (values []float64) float64, fill FillPolicy) Series {
+  if len(series) == 0 {
+    return series
+  }
+
+  published := Series{}
+  for _, point := range series {
+    if !point.Filled && !math.IsNaN(point.Value) {
+      published = append(published, point)
+    }
+  }
+
+  return fillGaps(published.Rebucket(width, aggregate), series[0].Timestamp, width, fill)
+}
+
+// Collapse gaps in the panel's own series, cutting the same buckets from the overlays so they stay lined up
+func compactPanel(panel Panel, minGap time.Duration) Panel {
+  var collapsed map[int64]bool
+  panel.Series, panel.Events, collapsed = panel.Series.CompactGaps(minGap)
+  overlays := make([]Panel, len(panel.Overlays))
+  for i, overlay := range panel.Overlays {
+    overlay.Series = overlay.Series.Without(collapsed)
+    overlays[i] = overlay
+  }
+  panel.Overlays = overlays
+
+  return panel
+}
+
+// Buckets a collapsed gap is drawn as, enough to show a break in the line
+const compactedGapBuckets = 3
+
+// Collapse each run of filled buckets spanning at least minGap into a short break, so sparse data gets most of the width. Returns an event naming each collapsed gap, and the timestamps cut.
+func (series Series) CompactGaps(minGap time.Duration) (Series, []Event, map[int64]bool) {
+  compacted := Series{}
+  events := []Event{}
+  collapsed := map[int64]bool{}
+  if len(series) < 2 {
+    return series, events, collapsed
+  }
+  step := series[1].Timestamp.Sub(series[0].Timestamp)
+
+  for i := 0; i < len(series); {
+    if !series[i].Filled {
+      compacted = append(compacted, series[i])
+      i++
+      continue
+    }
+
+    j := i
+    for j < len(series) && series[j].Filled {
+      j++
+    }
+    gap := series[j - 1].Timestamp.Sub(series[i].Timestamp) + step
+    if gap < minGap || j - i <= compactedGapBuckets {
+      compacted = append(compacted, series[i:j]...)
+      i = j
+      continue
+    }
+
+    for k := i; k < j; k++ {
+      if k < i + compactedGapBuckets {
+        compacted = append(compacted, Point{ Timestamp: series[k].Timestamp, Value: math.NaN(), Filled: true })
+      } else {
+        collapsed[series[k].Timestamp.Unix()] = true
+      }
+    }
+    events = append(events, Event{ At: series[i + 1].Timestamp, Label: fmt.Sprintf("…%s gap…", gap) })
+    i = j
+  }
+
+  return compacted, events, collapsed
+}
+
+// The series without the buckets at the given Unix timestamps
+func (series Series) Without(timestamps map[int64]bool) Series {
+  kept := Series{}
+  for _, point := range series {
+    if !timestamps[point.Timestamp.Unix()] {
+      kept = append(kept, point)
+    }
+  }
+
+  return kept
+}
+
+// Drop the final bucket if its period runs past the end of the window, i.e. CloudWatch was still aggregating it
+func (series Series) DropPartial(period time.Duration, end time.Time) Series {
+  if len(series) == 0 {
+    return series
+  }
+
+  last := series[len(series) - 1]
+  if last.Timestamp.Add(period).After(end) {
+    return series[:len(series) - 1]
+  }
+
+  return series
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+  "math"
+  "testing"
+  "time"
+)
+
+func TestAlignPanelsKeepsEachPanelsBounds(t *testing.T) {
+  period := time.Minute
+  start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+  series := func (from int, values ...float64) Series {
+    built := Series{}
+    for i, value := range values {
+      built = append(built, Point{ Timestamp: start.Add(time.Duration(from + i) * period), Value: value })
+    }
+    return built
+  }
+
+  // The second metric started publishing late and the third stopped early, each on its own scale
+  panels := []Panel{
+    { Label: "big", Series: series(0, 900, 1000, 950, 980, 1000, 990) },
+    { Label: "late", Series: series(3, 2, 5, 4), Overlays: []Panel{{ Label: "late overlay", Series: series(3, -1, 0, 1) }} },
+    { Label: "early", Series: series(0, -30, -10) },
+  }
+  type bounds struct{ min, max float64 }
+  want := []bounds{}
+  for _, panel := range panels {
+    min, max, _ := panel.Series.Bounds()
+    want = append(want, bounds{ min, max })
+  }
+  overlayMin, overlayMax, _ := panels[1].Overlays[0].Series.Bounds()
+
+  alignPanels(panels, period)
+
+  for i, panel := range panels {
+    if len(panel.Series) != 6 {
+      t.Errorf("%s has %d buckets, want the 6 the widest panel spans", panel.Label, len(panel.Series))
+    }
+    if first, last := panel.Series[0].Timestamp, panel.Series[len(panel.Series) - 1].Timestamp; !first.Equal(start) || !last.Equal(start.Add(5 * period)) {
+      t.Errorf("%s spans %s to %s, want %s to %s", panel.Label, first, last, start, start.Add(5 * period))
+    }
+    min, max, ok := panel.Series.Bounds()
+    if !ok || min != want[i].min || max != want[i].max {
+      t.Errorf("%s has bounds %g to %g after aligning, want its own %g to %g", panel.Label, min, max, want[i].min, want[i].max)
+    }
+    for _, point := range panel.Series {
+      if point.Filled && !math.IsNaN(point.Value) {
+        t.Errorf("%s was padded with %g at %s, want a break", panel.Label, point.Value, point.Timestamp)
+      }
+    }
+  }
+
+  overlay := panels[1].Overlays[0].Series
+  if len(overlay) != len(panels[1].Series) {
+    t.Errorf("overlay has %d buckets, want the panel's %d", len(overlay), len(panels[1].Series))
+  }
+  if min, max, _ := overlay.Bounds(); min != overlayMin || max != overlayMax {
+    t.Errorf("overlay has bounds %g to %g after aligning, want its own %g to %g", min, max, overlayMin, overlayMax)
+  }
+}
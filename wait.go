@@ -0,0 +1,51 @@
+package main
+
+import (
+  "errors"
+  "fmt"
+  "os"
+  "time"
+)
+
+// How often -wait-for-data checks for the first datapoint. Metrics are published every minute at best, so more often would only cost API calls.
+const waitPollInterval = 15 * time.Second
+
+var spinnerFrames = []rune("⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏")
+
+// Poll until the window has data or -wait-for-data runs out, spinning on stderr in the meantime. Returns the end of the window that had data.
+func (client Client) waitForData(options Options) (time.Time, []Panel, error) {
+  started := time.Now()
+  spinner := time.NewTicker(100 * time.Millisecond)
+  defer spinner.Stop()
+  // Leave the terminal on a clean line whatever happens
+  defer func () {
+    if !quiet {
+      fmt.Fprint(os.Stderr, "\r\x1b[K")
+    }
+  }()
+
+  frame := 0
+  for {
+    nextPoll := time.After(waitPollInterval)
+    for polling := true; polling; {
+      select {
+      case <-spinner.C:
+        if !quiet {
+          fmt.Fprintf(os.Stderr, "\r%c Waiting for data (%s of %s)", spinnerFrames[frame % len(spinnerFrames)], time.Since(started).Round(time.Second), options.waitForData)
+        }
+        frame++
+      case <-nextPoll:
+        polling = false
+      }
+    }
+
+    start, end := options.window(time.Now())
+    panels, err := client.fetchPanels(options, start, end)
+    if !errors.Is(err, ErrNoData) {
+      return end, panels, err
+    }
+    if time.Since(started) >= options.waitForData {
+      return end, panels, fmt.Errorf("gave up after waiting %s: %w", options.waitForData, err)
+    }
+  }
+}
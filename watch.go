@@ -0,0 +1,40 @@
+package main
+
+import (
+  "errors"
+  "fmt"
+  "os"
+  "strconv"
+  "time"
+)
+
+// Poll the series every -poll-interval and alert as soon as the latest value goes above -alert-above
+func (client Client) watch(options Options) error {
+  for {
+    end := time.Now()
+    panels, err := client.fetchPanels(options, end.Add(options.lookback), end)
+    if err != nil && (!errors.Is(err, ErrNoData) || options.failOnEmpty) {
+      return err
+    }
+
+    breached := false
+    for _, panel := range panels {
+      latest, ok := panel.Series.Last()
+      if !ok {
+        continue
+      }
+      value := strconv.FormatFloat(latest.Value, 'f', -1, 64)
+      if latest.Value > options.alertAbove.value {
+        breached = true
+        fmt.Printf("ALERT %s %s=%s is above %s\n", formatTime(latest.Timestamp), panel.Label, value, options.alertAbove.String())
+      } else if options.info {
+        fmt.Fprintf(os.Stderr, "%s %s=%s\n", formatTime(latest.Timestamp), panel.Label, value)
+      }
+    }
+    if breached && !options.alertContinue {
+      return ErrAlert
+    }
+
+    time.Sleep(options.pollInterval)
+  }
+}